@@ -56,7 +56,7 @@ func TestGetUserSubmissions(t *testing.T) {
 
 		subResp := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"score": 10,
 				"link":  "www.youtube.com",
@@ -65,13 +65,13 @@ func TestGetUserSubmissions(t *testing.T) {
 		assert.Equal(t, 200, subResp.Code)
 		if submissionResp, getResp := getAccountSubmissions(t, api, users["player2"]); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 1, len(submissionResp.Submissions))
-			assert.Equal(t, 10, submissionResp.Submissions[0].Score)
+			assert.Equal(t, "10", submissionResp.Submissions[0].Score.String())
 			assert.False(t, submissionResp.Submissions[0].TimeCreated.IsZero())
 		}
 
 		submissionResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"score": 11,
 				"link":  "www.youtube.com/1",
@@ -88,6 +88,40 @@ func TestGetUserSubmissions(t *testing.T) {
 
 }
 
+func TestGetUserSubmissionsPagination(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["Anonymous1"])
+
+		for score := range 3 {
+			subResp := api.Post(
+				fmt.Sprintf("/leaderboard/%s/submission", id),
+				authHeader(users["player2"]),
+				map[string]any{
+					"score": score,
+					"link":  "www.youtube.com",
+				})
+			assert.Equal(t, 200, subResp.Code)
+		}
+
+		firstPage := api.Get(fmt.Sprintf("/account/%s/submissions?limit=2", users["player2"]))
+		assert.Equal(t, 200, firstPage.Code)
+		var firstResp AccountSubmissionsResponseBody
+		json.Unmarshal(firstPage.Body.Bytes(), &firstResp)
+		assert.Equal(t, 2, len(firstResp.Submissions))
+
+		next := nextPage(firstPage)
+		if assert.NotEmpty(t, next) {
+			secondPage := api.Get(next)
+			assert.Equal(t, 200, secondPage.Code)
+			var secondResp AccountSubmissionsResponseBody
+			json.Unmarshal(secondPage.Body.Bytes(), &secondResp)
+			assert.Equal(t, 1, len(secondResp.Submissions))
+			assert.NotEqual(t, firstResp.Submissions[1].ID, secondResp.Submissions[0].ID)
+			assert.Empty(t, nextPage(secondPage))
+		}
+	})
+}
+
 func TestLinkAnonymousAccount(t *testing.T) {
 	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
 
@@ -99,7 +133,7 @@ func TestLinkAnonymousAccount(t *testing.T) {
 
 		postResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["Anonymous1"]),
+			authHeader(users["Anonymous1"]),
 			map[string]any{
 				"score": 10,
 				"link":  "www.youtube.com",
@@ -109,7 +143,7 @@ func TestLinkAnonymousAccount(t *testing.T) {
 
 		linkResponse := api.Post(
 			"/account/link_anonymous",
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"anon_id": users["Anonymous1"],
 			})
@@ -127,7 +161,7 @@ func TestActiveLeaderboardCount(t *testing.T) {
 	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
 		for i := range 50 {
 			resp := api.Post("/leaderboard",
-				fmt.Sprintf("UserID: %s", users["player2"]),
+				authHeader(users["player2"]),
 				map[string]any{
 					"title":         "My First Leaderboard",
 					"highest_first": true,
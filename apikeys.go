@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+)
+
+const API_KEY_CONTEXT_KEY = "api_key"
+
+// Bearer tokens starting with this are API keys rather than sessions.
+const api_key_prefix = "tk_"
+
+// What an API key may do on its leaderboard.
+const (
+	scope_submit = "submit"
+	scope_verify = "verify"
+	// Only checked for leaderboards anonymous requests can't read, see
+	// requireLeaderboardAccess.
+	scope_read = "read"
+)
+
+const max_api_keys = 25
+
+// newAPIKey returns a new key and the prefix shown for it.
+func newAPIKey() (string, string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	key := api_key_prefix + hex.EncodeToString(b)
+	return key, key[:len(api_key_prefix)+8]
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (k *APIKey) hasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAPIKey returns the API key the request was authenticated with, or nil
+// for user sessions. It's a 403 if the key isn't for leaderboard or lacks scope.
+func requireAPIKey(ctx context.Context, leaderboard uuid.UUID, scope string) (*APIKey, error) {
+	key, ok := ctx.Value(API_KEY_CONTEXT_KEY).(*APIKey)
+	if !ok || key == nil {
+		return nil, nil
+	}
+	if key.Leaderboard != leaderboard {
+		return nil, huma.Error403Forbidden("API key is for a different leaderboard.")
+	}
+	if !key.hasScope(scope) {
+		return nil, huma.Error403Forbidden("API key doesn't have the " + scope + " scope.")
+	}
+	return key, nil
+}
+
+func (app *App) addAPIKey(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	NewAPIKeyBody
+}) (*APIKeyResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	keys, db_err := app.st.getAPIKeys(ctx, input.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if len(keys) >= max_api_keys {
+		return nil, huma.Error409Conflict("Revoke an API key before adding another.")
+	}
+
+	secret, prefix := newAPIKey()
+	key, db_err := app.st.newAPIKey(ctx, input.ID, user.ID, input.Body.Name, prefix, hashAPIKey(secret), input.Body.Scopes)
+	if db_err != nil {
+		return nil, db_err
+	}
+	key.Key = secret
+	return &APIKeyResponse{Body: key}, nil
+}
+
+func (app *App) getAPIKeys(ctx context.Context, input *struct {
+	LeaderboardIDParam
+}) (*APIKeysResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	keys, db_err := app.st.getAPIKeys(ctx, input.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	resp := &APIKeysResponse{}
+	resp.Body.Keys = keys
+	return resp, nil
+}
+
+func (app *App) revokeAPIKey(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	APIKeyIDParam
+}) (*MessageResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	count, db_err := app.st.revokeAPIKey(ctx, input.ID, input.KeyID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if count == 0 {
+		return nil, huma.Error404NotFound("API key not found.")
+	}
+	resp := &MessageResponse{}
+	resp.Body.Message = "API key revoked."
+	return resp, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const USER_CONTEXT_KEY = "user"
+
+// bearerToken extracts the token from an `Authorization: Bearer <token>` header value.
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, len(token) > 0
+}
+
+// AuthMiddleware resolves the bearer token against the session table and puts
+// the authenticated user into the request context. Requests without a valid,
+// unexpired session are rejected before reaching the handler. API keys are
+// put into the context instead, for handlers that accept them to check.
+func (app *App) AuthMiddleware(ctx huma.Context, next func(huma.Context)) {
+	token, ok := bearerToken(ctx.Header("Authorization"))
+	if !ok {
+		huma.WriteErr(app.api, ctx, http.StatusUnauthorized, "Missing or malformed bearer token.")
+		return
+	}
+
+	if strings.HasPrefix(token, api_key_prefix) {
+		key, db_err := app.st.useAPIKey(ctx.Context(), hashAPIKey(token))
+		if db_err == pgx.ErrNoRows {
+			huma.WriteErr(app.api, ctx, http.StatusUnauthorized, "API key is invalid or has been revoked.")
+			return
+		}
+		if db_err != nil {
+			huma.WriteErr(app.api, ctx, http.StatusInternalServerError, "Could not verify API key.", db_err)
+			return
+		}
+		next(huma.WithValue(ctx, API_KEY_CONTEXT_KEY, &key))
+		return
+	}
+
+	user, db_err := app.st.getSessionUser(ctx.Context(), token)
+	if db_err == pgx.ErrNoRows {
+		huma.WriteErr(app.api, ctx, http.StatusUnauthorized, "Session is invalid or has expired.")
+		return
+	}
+	if db_err != nil {
+		huma.WriteErr(app.api, ctx, http.StatusInternalServerError, "Could not verify session.", db_err)
+		return
+	}
+
+	ctx = huma.WithValue(ctx, USER_CONTEXT_KEY, &user)
+	next(ctx)
+}
+
+// authenticated marks an operation as requiring a user session.
+func (app *App) authenticated(o *huma.Operation) {
+	o.Middlewares = append(huma.Middlewares{app.AuthMiddleware}, o.Middlewares...)
+	o.Security = []map[string][]string{{"bearer": {}}}
+}
+
+// OptionalAuthMiddleware is AuthMiddleware for requests that may also be
+// made anonymously. Only a bearer token that is given has to be valid.
+func (app *App) OptionalAuthMiddleware(ctx huma.Context, next func(huma.Context)) {
+	if len(ctx.Header("Authorization")) == 0 {
+		next(ctx)
+		return
+	}
+	app.AuthMiddleware(ctx, next)
+}
+
+// optionallyAuthenticated marks an operation as showing more to signed in
+// users, e.g. private leaderboards they're a member of.
+func (app *App) optionallyAuthenticated(o *huma.Operation) {
+	o.Middlewares = append(huma.Middlewares{app.OptionalAuthMiddleware}, o.Middlewares...)
+	o.Security = []map[string][]string{{}, {"bearer": {}}}
+}
+
+// requireUser returns the user authenticated by AuthMiddleware.
+func requireUser(ctx context.Context) (*User, error) {
+	if user, ok := ctx.Value(USER_CONTEXT_KEY).(*User); ok && user != nil {
+		return user, nil
+	}
+	if key, ok := ctx.Value(API_KEY_CONTEXT_KEY).(*APIKey); ok && key != nil {
+		return nil, huma.Error403Forbidden("API keys can't be used for this endpoint.")
+	}
+	return nil, huma.Error401Unauthorized("Missing or malformed bearer token.")
+}
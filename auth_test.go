@@ -0,0 +1,177 @@
+//go:build integration
+// +build integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingSession(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		resp := api.Post("/leaderboard",
+			map[string]any{
+				"title":         "My First Leaderboard",
+				"highest_first": true,
+				"start":         time.Now().Format(time.RFC3339),
+			})
+		assert.Equal(t, 401, resp.Code)
+
+		id := createBasicLeaderboard(t, api, users["player2"])
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			"Authorization: Bearer not-a-real-token",
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 9,
+			})
+		assert.Equal(t, 401, postResp.Code)
+	})
+}
+
+func TestExpiredSession(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["expired"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 9,
+			})
+		assert.Equal(t, 401, postResp.Code)
+
+		linkResponse := api.Post(
+			"/account/link_anonymous",
+			authHeader(users["expired"]),
+			map[string]any{
+				"anon_id": users["Anonymous1"],
+			})
+		assert.Equal(t, 401, linkResponse.Code)
+	})
+}
+
+func TestUserIDHeaderIgnored(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createVerifiedLeaderboard(t, api, users["player2"])
+
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player3"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 9,
+			})
+		assert.Equal(t, 200, postResp.Code)
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Scores))
+			assert.Equal(t, users["player3"], lResp.Scores[0].User.ID)
+
+			updateResp := api.Patch(
+				fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, lResp.Scores[0].ID),
+				fmt.Sprintf("UserID: %s", users["player2"]),
+				map[string]any{
+					"is_valid": true,
+				})
+			assert.Equal(t, 401, updateResp.Code)
+		}
+	})
+}
+
+func createAPIKey(t *testing.T, api humatest.TestAPI, leaderboard_id LeaderboardShortID, user_id string, scopes ...string) APIKey {
+	t.Helper()
+	resp := api.Post(fmt.Sprintf("/leaderboard/%s/keys", leaderboard_id),
+		authHeader(user_id),
+		map[string]any{
+			"name":   "game servers",
+			"scopes": scopes,
+		})
+	assert.Equal(t, 200, resp.Code)
+	var key APIKey
+	json.Unmarshal(resp.Body.Bytes(), &key)
+	return key
+}
+
+func TestAPIKeys(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createVerifiedLeaderboard(t, api, users["player2"])
+		other := createBasicLeaderboard(t, api, users["player2"])
+		submission := fmt.Sprintf("/leaderboard/%s/submission", id)
+
+		notOwnerResp := api.Post(fmt.Sprintf("/leaderboard/%s/keys", id),
+			authHeader(users["player1"]),
+			map[string]any{"name": "mine", "scopes": []string{"submit"}})
+		assert.Equal(t, 403, notOwnerResp.Code)
+
+		submitKey := createAPIKey(t, api, id, users["player2"], "submit")
+		assert.True(t, strings.HasPrefix(submitKey.Key, "tk_"))
+		assert.True(t, strings.HasPrefix(submitKey.Key, submitKey.Prefix))
+		keyHeader := "Authorization: Bearer " + submitKey.Key
+
+		listResp := api.Get(fmt.Sprintf("/leaderboard/%s/keys", id), authHeader(users["player2"]))
+		assert.Equal(t, 200, listResp.Code)
+		assert.Contains(t, listResp.Body.String(), submitKey.Prefix)
+		assert.NotContains(t, listResp.Body.String(), submitKey.Key)
+
+		postResp := api.Post(submission, keyHeader, map[string]any{
+			"link":    "www.youtube.com",
+			"score":   9,
+			"user_id": users["player1"],
+		})
+		assert.Equal(t, 200, postResp.Code)
+		var posted SubmissionResponseBody
+		json.Unmarshal(postResp.Body.Bytes(), &posted)
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) && assert.Equal(t, 1, len(lResp.Scores)) {
+			assert.Equal(t, users["player1"], lResp.Scores[0].User.ID)
+		}
+
+		noPlayerResp := api.Post(submission, keyHeader, map[string]any{"link": "www.youtube.com", "score": 9})
+		assert.Equal(t, 422, noPlayerResp.Code)
+		unknownPlayerResp := api.Post(submission, keyHeader, map[string]any{"link": "www.youtube.com", "score": 9, "user_id": "nobody"})
+		assert.Equal(t, 404, unknownPlayerResp.Code)
+
+		otherBoardResp := api.Post(fmt.Sprintf("/leaderboard/%s/submission", other), keyHeader, map[string]any{
+			"link": "www.youtube.com", "score": 9, "user_id": users["player1"],
+		})
+		assert.Equal(t, 403, otherBoardResp.Code)
+
+		verifyPath := fmt.Sprintf("%s/%s/verify", submission, posted.ID)
+		noScopeResp := api.Patch(verifyPath, keyHeader, map[string]any{"is_valid": true})
+		assert.Equal(t, 403, noScopeResp.Code)
+		ownerOnlyResp := api.Patch(fmt.Sprintf("/leaderboard/%s", id), keyHeader, map[string]any{"title": "Mine now"})
+		assert.Equal(t, 403, ownerOnlyResp.Code)
+
+		verifyKey := createAPIKey(t, api, id, users["player2"], "verify", "read")
+		verifyResp := api.Patch(verifyPath, "Authorization: Bearer "+verifyKey.Key, map[string]any{"is_valid": true})
+		assert.Equal(t, 200, verifyResp.Code)
+
+		// Users can only submit for themselves.
+		forOtherResp := api.Post(submission, authHeader(users["player3"]), map[string]any{
+			"link": "www.youtube.com", "score": 9, "user_id": users["player1"],
+		})
+		assert.Equal(t, 403, forOtherResp.Code)
+		forSelfResp := api.Post(submission, authHeader(users["player3"]), map[string]any{
+			"link": "www.youtube.com", "score": 9, "user_id": users["player3"],
+		})
+		assert.Equal(t, 200, forSelfResp.Code)
+
+		revokeResp := api.Delete(fmt.Sprintf("/leaderboard/%s/keys/%s", id, submitKey.ID), authHeader(users["player2"]))
+		assert.Equal(t, 200, revokeResp.Code)
+		revokedResp := api.Post(submission, keyHeader, map[string]any{
+			"link": "www.youtube.com", "score": 9, "user_id": users["player1"],
+		})
+		assert.Equal(t, 401, revokedResp.Code)
+		revokeResp = api.Delete(fmt.Sprintf("/leaderboard/%s/keys/%s", id, submitKey.ID), authHeader(users["player2"]))
+		assert.Equal(t, 404, revokeResp.Code)
+	})
+}
@@ -1,18 +1,85 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 
 	"github.com/gofrs/uuid/v5"
 )
 
-func initCache() *lru.TwoQueueCache[uuid.UUID, *LeaderboardResponse] {
-	cache, err := lru.New2Q[uuid.UUID, *LeaderboardResponse](128)
+// Channel instances announce leaderboard cache invalidations on.
+const cache_invalidation_channel = "leaderboard_cache"
+
+// Longest a cached first page is served for, in case an invalidation from
+// another instance was missed.
+const cache_ttl = time.Minute
+
+// InvalidationBus broadcasts that a leaderboard's cached rankings are stale
+// to every other instance.
+type InvalidationBus interface {
+	PublishInvalidation(ctx context.Context, leaderboard uuid.UUID) error
+}
+
+type cachedLeaderboard struct {
+	resp    *LeaderboardResponse
+	expires time.Time
+}
+
+// LeaderboardCache holds the first page of rankings for recently read
+// leaderboards. Removing an entry also removes it on other instances when a
+// bus is set.
+type LeaderboardCache struct {
+	entries *lru.TwoQueueCache[uuid.UUID, cachedLeaderboard]
+	ttl     time.Duration
+	bus     InvalidationBus
+}
+
+func initCache() *LeaderboardCache {
+	entries, err := lru.New2Q[uuid.UUID, cachedLeaderboard](128)
 
 	if err != nil {
 		log.Fatalf("Failed to initialize cache: %s", err)
 	}
-	return cache
+	return &LeaderboardCache{entries: entries, ttl: cache_ttl}
+}
+
+func (c *LeaderboardCache) Get(leaderboard uuid.UUID) (*LeaderboardResponse, bool) {
+	entry, ok := c.entries.Get(leaderboard)
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		c.entries.Remove(leaderboard)
+		return nil, false
+	}
+	return entry.resp, true
+}
+
+func (c *LeaderboardCache) Add(leaderboard uuid.UUID, resp *LeaderboardResponse) {
+	c.entries.Add(leaderboard, cachedLeaderboard{resp: resp, expires: time.Now().Add(c.ttl)})
+}
+
+// Remove drops the leaderboard here and tells other instances to drop it.
+func (c *LeaderboardCache) Remove(leaderboard uuid.UUID) {
+	c.entries.Remove(leaderboard)
+	if c.bus == nil {
+		return
+	}
+	if err := c.bus.PublishInvalidation(context.Background(), leaderboard); err != nil {
+		log.Printf("Could not publish cache invalidation for %s: %v", leaderboard, err)
+	}
+}
+
+// Invalidate drops the leaderboard named by an invalidation payload from this
+// instance only.
+func (c *LeaderboardCache) Invalidate(payload string) {
+	leaderboard, err := uuid.FromString(payload)
+	if err != nil {
+		log.Printf("Ignoring malformed cache invalidation %q: %v", payload, err)
+		return
+	}
+	c.entries.Remove(leaderboard)
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingBus struct {
+	published []uuid.UUID
+}
+
+func (b *recordingBus) PublishInvalidation(ctx context.Context, leaderboard uuid.UUID) error {
+	b.published = append(b.published, leaderboard)
+	return nil
+}
+
+func TestLeaderboardCache(t *testing.T) {
+	bus := &recordingBus{}
+	cache := initCache()
+	cache.bus = bus
+	id := uuid.Must(uuid.NewV4())
+	resp := &LeaderboardResponse{Status: 200}
+
+	cache.Add(id, resp)
+	cached, ok := cache.Get(id)
+	assert.True(t, ok)
+	assert.Same(t, resp, cached)
+
+	// Invalidations from other instances only drop the local entry.
+	cache.Invalidate(id.String())
+	_, ok = cache.Get(id)
+	assert.False(t, ok)
+	assert.Empty(t, bus.published)
+
+	cache.Add(id, resp)
+	cache.Remove(id)
+	_, ok = cache.Get(id)
+	assert.False(t, ok)
+	assert.Equal(t, []uuid.UUID{id}, bus.published)
+
+	cache.Invalidate("not-a-uuid")
+}
+
+func TestLeaderboardCacheExpiry(t *testing.T) {
+	cache := initCache()
+	cache.ttl = time.Millisecond
+	id := uuid.Must(uuid.NewV4())
+
+	cache.Add(id, &LeaderboardResponse{Status: 200})
+	time.Sleep(5 * time.Millisecond)
+	_, ok := cache.Get(id)
+	assert.False(t, ok)
+}
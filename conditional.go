@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConditionalParams are the validators a client sends to revalidate a cached
+// read. They are plain strings so that malformed values are ignored, as HTTP
+// requires, instead of failing the request.
+type ConditionalParams struct {
+	IfNoneMatch     string `header:"If-None-Match" doc:"ETags of the client's cached copies. A 304 is returned if any matches."`
+	IfModifiedSince string `header:"If-Modified-Since" doc:"Time the client's cached copy was last modified. A 304 is returned if there have been no changes since. Ignored if If-None-Match is sent."`
+}
+
+// notModified reports whether the client's cached copy is current. When
+// If-None-Match is sent If-Modified-Since is ignored, and last modified
+// times are compared at the second granularity of HTTP dates.
+func (p ConditionalParams) notModified(etag string, last_modified time.Time) bool {
+	if len(p.IfNoneMatch) != 0 {
+		for _, tag := range strings.Split(p.IfNoneMatch, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if len(p.IfModifiedSince) == 0 || last_modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(p.IfModifiedSince)
+	if err != nil {
+		return false
+	}
+	return !httpTime(last_modified).After(since)
+}
+
+// httpTime truncates t to what an HTTP date can represent.
+func httpTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// strongETag returns an ETag that changes whenever the JSON encoding of any of
+// parts does.
+func strongETag(parts ...any) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, part := range parts {
+		enc.Encode(part)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
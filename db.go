@@ -2,11 +2,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"time"
 
-	_ "embed"
-
 	"github.com/gofrs/uuid/v5"
 	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
 	"github.com/jackc/pgx/v5"
@@ -30,23 +29,135 @@ type LeaderboardConfig struct {
 	HighestFirst bool       `json:"highest_first" example:"true" doc:"If true, higher scores/times are ranked higher, e.g. highest score is first, second highest is second."`
 	IsTime       bool       `json:"is_time" example:"false" doc:"If true, leaderboards scores are time values, e.g. 00:32"`
 	NeedsVerify  bool       `json:"verify" example:"true" doc:"If true, submissions need to be verified before they show up on the leaderboard."`
-	Stop         *time.Time `json:"stop,omitempty"  format:"date-time" example:"2024-09-05T14\:35" doc:"Datetime when the leaderboard closes. Times before the start value or empty mean the leaderboard accept submissions until the leaderboard is archived."`
-	Start        time.Time  `json:"start" format:"date-time" example:"2024-09-05T14\:35" doc:"Datetime when the leaderboard opens. Default is at time of leaderboard creation."`
+	Stop         *time.Time `json:"stop,omitempty"  format:"date-time" example:"2024-09-05T14:35:00Z" doc:"Datetime when the leaderboard closes. Times before the start value or empty mean the leaderboard accept submissions until the leaderboard is archived."`
+	Start        time.Time  `json:"start" format:"date-time" example:"2024-09-05T14:35:00Z" doc:"Datetime when the leaderboard opens. Default is at time of leaderboard creation."`
+	RankingMode  string     `json:"ranking_mode,omitempty" enum:"all,best,latest" default:"all" doc:"Which submissions are ranked: every submission, each user's best submission, or each user's latest submission."`
+	TiePolicy    string     `json:"tie_policy,omitempty" enum:"standard,dense,earliest" default:"standard" doc:"How tied scores are ranked: standard competition (1-2-2-4), dense (1-2-2-3), or earliest submission wins (1-2-3-4)."`
+	Precision    *int       `json:"precision,omitempty" minimum:"0" maximum:"9" doc:"Number of decimal places allowed in scores. Defaults to 3 on time leaderboards, for milliseconds, and 0 otherwise."`
+	Recurrence   string     `json:"recurrence,omitempty" example:"daily" doc:"Reset schedule: daily, weekly (Mondays), monthly, or a five-field cron expression. Omit for a single period."`
+	Timezone     string     `json:"timezone,omitempty" default:"UTC" example:"America/New_York" doc:"IANA timezone the reset schedule is evaluated in."`
+	Visibility   string     `json:"visibility,omitempty" enum:"public,unlisted,private" default:"public" doc:"Who can see the leaderboard: anyone, only people with its UUID or slug, or only its creator, verifiers and members."`
+	Entry        string     `json:"entry,omitempty" enum:"open,invite,approval" default:"open" doc:"Who can submit: anyone, or only members, who join with an invite code or, with approval, also by asking the creator."`
+	GracePeriod  int        `json:"grace_period,omitempty" minimum:"0" maximum:"86400" example:"300" doc:"Seconds after stop that late submissions are still accepted and ranked."`
+}
+
+// LeaderboardUpdate holds the leaderboard settings that can be changed after
+// creation. Nil fields are left unchanged. Recurrence and Timezone can't be
+// changed, and are only here so attempts to are rejected with a reason.
+type LeaderboardUpdate struct {
+	Title        *string      `json:"title,omitempty" example:"My First Leaderboard" doc:"Leaderboard title"`
+	HighestFirst *bool        `json:"highest_first,omitempty" example:"true" doc:"If true, higher scores/times are ranked higher."`
+	NeedsVerify  *bool        `json:"verify,omitempty" example:"true" doc:"If true, submissions need to be verified before they show up on the leaderboard."`
+	Stop         NullableTime `json:"stop,omitempty" example:"2024-09-05T14:35:00Z" doc:"Datetime when the leaderboard closes, or null to leave it open until it's archived."`
+	RankingMode  *string      `json:"ranking_mode,omitempty" enum:"all,best,latest" doc:"Which submissions are ranked: every submission, each user's best submission, or each user's latest submission."`
+	TiePolicy    *string      `json:"tie_policy,omitempty" enum:"standard,dense,earliest" doc:"How tied scores are ranked: standard competition (1-2-2-4), dense (1-2-2-3), or earliest submission wins (1-2-3-4)."`
+	Precision    *int         `json:"precision,omitempty" minimum:"0" maximum:"9" doc:"Number of decimal places allowed in scores. Existing scores are kept as submitted."`
+	Visibility   *string      `json:"visibility,omitempty" enum:"public,unlisted,private" doc:"Who can see the leaderboard: anyone, only people with its UUID or slug, or only its creator, verifiers and members."`
+	Entry        *string      `json:"entry,omitempty" enum:"open,invite,approval" doc:"Who can submit: anyone, or only members, who join with an invite code or, with approval, also by asking the creator."`
+	Recurrence   *string      `json:"recurrence,omitempty" doc:"Can't be changed after creation, so giving it is an error."`
+	Timezone     *string      `json:"timezone,omitempty" doc:"Can't be changed after creation, so giving it is an error."`
+	GracePeriod  *int         `json:"grace_period,omitempty" minimum:"0" maximum:"86400" example:"300" doc:"Seconds after stop that late submissions are still accepted and ranked."`
 }
 
 type HistoryEntry struct {
+	ID            uuid.UUID `json:"id"`
 	Comment       string    `json:"comment"`
 	TimeSubmitted time.Time `json:"submitted_at"`
 	Author        User      `json:"author"`
 	Action        string    `json:"action"`
+	PreviousScore *Score    `json:"previous_score,omitempty" doc:"Score before an edit."`
+	Score         *Score    `json:"score,omitempty" doc:"Score after an edit."`
+	PreviousLink  *string   `json:"previous_link,omitempty" doc:"Link before an edit."`
+	Link          *string   `json:"link,omitempty" doc:"Link after an edit."`
+}
+
+type VerifierHistoryEntry struct {
+	ID          uuid.UUID `json:"id"`
+	Verifier    User      `json:"verifier" doc:"User added or removed as a verifier."`
+	Author      User      `json:"author" doc:"User who made the change."`
+	Action      string    `json:"action" enum:"add,remove"`
+	TimeChanged time.Time `json:"changed_at"`
 }
 
 type Ranking struct {
 	User          `json:"user"`
-	ID            uuid.UUID `json:"id"`
-	Score         int       `json:"score"`
-	TimeSubmitted time.Time `json:"submitted_at"`
-	Verified      *bool     `json:"verified,omitempty"`
+	Rank          int               `json:"rank" doc:"Position on the leaderboard, with ties ranked by the leaderboard's tie policy."`
+	ID            SubmissionShortID `json:"id" example:"A4xACrHGM"`
+	UUID          uuid.UUID         `json:"-"`
+	Score         Score             `json:"score"`
+	DisplayScore  string            `json:"display_score" example:"1:02:33.450" doc:"Score formatted for display, as a time on time leaderboards."`
+	TimeSubmitted time.Time         `json:"submitted_at"`
+	Verified      *bool             `json:"verified,omitempty"`
+}
+
+type UserRank struct {
+	User       `json:"user"`
+	Rank       int     `json:"rank"`
+	Total      int     `json:"total" doc:"Number of ranked entries on the leaderboard."`
+	Percentile float64 `json:"percentile" doc:"Percentage of ranked entries at or below the user's rank."`
+	Best       Ranking `json:"best" doc:"The user's highest ranked submission."`
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url" example:"https://example.com/hooks/topktoday"`
+	Events    []string  `json:"events" doc:"Events sent to the URL."`
+	Secret    string    `json:"secret,omitempty" doc:"Key the X-Signature header is an HMAC-SHA256 of the body with. Only returned when the webhook is created."`
+	TimeAdded time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID          uuid.UUID       `json:"id"`
+	Event       string          `json:"event"`
+	Data        json.RawMessage `json:"data" doc:"Event data, as sent in the data field of the payload."`
+	Status      string          `json:"status" enum:"pending,delivered,failed"`
+	Attempts    int             `json:"attempts"`
+	LastStatus  *int            `json:"last_status_code,omitempty" doc:"HTTP status of the last attempt, if a response was received."`
+	LastError   *string         `json:"last_error,omitempty" doc:"Why the last attempt failed."`
+	NextAttempt *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt *time.Time      `json:"delivered_at,omitempty"`
+	TimeCreated time.Time       `json:"created_at"`
+}
+
+// queuedWebhook is a delivery claimed for sending.
+type queuedWebhook struct {
+	ID          uuid.UUID
+	Leaderboard LeaderboardShortID
+	Event       string
+	Data        json.RawMessage
+	TimeCreated time.Time
+	Attempts    int
+	URL         string
+	Secret      string
+}
+
+type APIKey struct {
+	ID            uuid.UUID          `json:"id"`
+	Leaderboard   uuid.UUID          `json:"-"`
+	LeaderboardID LeaderboardShortID `json:"leaderboard_id" example:"tYLfjGTh9"`
+	CreatedBy     string             `json:"created_by"`
+	Name          string             `json:"name" example:"EU game servers"`
+	Prefix        string             `json:"prefix" example:"tk_3f9a1c2e" doc:"Start of the key, to tell keys apart."`
+	Scopes        []string           `json:"scopes" doc:"What the key may do on the leaderboard."`
+	Key           string             `json:"key,omitempty" doc:"The key itself. Only returned when the key is created."`
+	TimeCreated   time.Time          `json:"created_at"`
+	LastUsed      *time.Time         `json:"last_used_at,omitempty"`
+}
+
+type Invite struct {
+	ID          uuid.UUID  `json:"id"`
+	Code        string     `json:"code" example:"3f9a1c2e7b4d8a60" doc:"Code entrants join the leaderboard with."`
+	CreatedBy   string     `json:"created_by"`
+	MaxUses     *int       `json:"max_uses,omitempty" doc:"How many entrants can join with the code, if limited."`
+	Uses        int        `json:"uses" doc:"How many entrants have joined with the code."`
+	TimeExpires *time.Time `json:"expires_at,omitempty"`
+	TimeCreated time.Time  `json:"created_at"`
+}
+
+type JoinRequest struct {
+	User          User      `json:"user"`
+	Message       string    `json:"message,omitempty"`
+	TimeRequested time.Time `json:"requested_at"`
 }
 
 type User struct {
@@ -55,47 +166,56 @@ type User struct {
 	TimeAdded *time.Time `json:"added_at,omitempty"`
 }
 
+type LeaderboardPeriod struct {
+	Number int        `json:"number" doc:"Period number, starting from 1."`
+	Start  *time.Time `json:"start,omitempty" doc:"When the period began. Omitted for the first period, which begins with the leaderboard."`
+	Stop   *time.Time `json:"stop,omitempty" doc:"When the period ends. Omitted if the leaderboard doesn't recur."`
+}
+
 type LeaderboardInfo struct {
-	ID          uuid.UUID `json:"id"`
-	Verifiers   []User    `json:"verifiers,omitempty"`
-	TimeCreated time.Time `json:"time_created"`
+	ID            LeaderboardShortID `json:"id" example:"tYLfjGTh9"`
+	UUID          uuid.UUID          `json:"uuid" doc:"Full leaderboard ID. Others can only open an unlisted leaderboard with this or its slug."`
+	Verifiers     []User             `json:"verifiers,omitempty"`
+	TimeCreated   time.Time          `json:"time_created"`
+	TimeArchived  *time.Time         `json:"archived_at,omitempty" doc:"Set once the creator archives the leaderboard."`
+	CurrentPeriod LeaderboardPeriod  `json:"current_period"`
+	SignedScores  bool               `json:"signed_submissions" doc:"Whether new scores must be signed with the leaderboard's signing secret."`
+	Slug          *string            `json:"slug,omitempty" example:"speedrun-any-percent" doc:"Vanity slug, usable in place of the ID."`
+	State         string             `json:"state" enum:"upcoming,open,closed" doc:"Whether the leaderboard is yet to start, accepting submissions, or closed after its stop time, grace period included, or archiving."`
 	LeaderboardConfig
 }
 
 type DetailedSubmission struct {
-	Link                   string    `json:"link,omitempty" format:"uri" example:"https://www.youtube.com/watch?v=rdx0TPjX1qE" doc:"Latest link for this submission."`
-	ID                     uuid.UUID `json:"id,omitempty"`
-	Score                  int       `json:"score" example:"12" doc:"Current score of submission."`
-	LeaderboardID          uuid.UUID `json:"leaderboard_id" example:"EfhxLZ9ck" doc:"9 character leaderboard ID used for querying."`
-	LeaderboardDisplayName string    `json:"leaderboard_title" example:"My First Leaderboard" doc:"Leaderboard title for associated submission."`
-	Submitter              *User     `json:"submitted_by,omitempty"`
-	TimeCreated            time.Time `json:"last_submitted"`
-	Verified               bool      `json:"verified" example:"true" doc:"Current verification status."`
+	Link                   string             `json:"link,omitempty" format:"uri" example:"https://www.youtube.com/watch?v=rdx0TPjX1qE" doc:"Latest link for this submission."`
+	ID                     SubmissionShortID  `json:"id,omitempty" example:"A4xACrHGM"`
+	UUID                   uuid.UUID          `json:"-"`
+	Score                  Score              `json:"score" doc:"Current score of submission."`
+	DisplayScore           string             `json:"display_score" example:"1:02:33.450" doc:"Score formatted for display, as a time on time leaderboards."`
+	LeaderboardID          LeaderboardShortID `json:"leaderboard_id" example:"tYLfjGTh9" doc:"9 character leaderboard ID used for querying."`
+	LeaderboardDisplayName string             `json:"leaderboard_title" example:"My First Leaderboard" doc:"Leaderboard title for associated submission."`
+	Submitter              *User              `json:"submitted_by,omitempty"`
+	TimeCreated            time.Time          `json:"last_submitted"`
+	Verified               bool               `json:"verified" example:"true" doc:"Current verification status."`
+	TimeWithdrawn          *time.Time         `json:"withdrawn_at,omitempty" doc:"Set once the submitter withdraws the submission from the leaderboard."`
 }
 
-//go:embed init.sql
-var init_file string
-
+// NewDBConn opens a connection pool. The schema must already be migrated, see runMigrations.
 func NewDBConn(ctx context.Context, connURL string) DB {
 	dbconfig, err := pgxpool.ParseConfig(connURL)
 	if err != nil {
 		log.Fatal(err)
 	}
 	dbconfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
-
-		conn.Exec(ctx, `DROP TABLE IF EXISTS leaderboards, submissions, verifiers, submission_updates, customers;`)
-		_, err = conn.Exec(ctx, init_file)
-		if err != nil {
-			log.Fatal(err)
-		}
 		pgxuuid.Register(conn.TypeMap())
 
-		dt, err := conn.LoadType(ctx, "submission_action")
-		if err != nil {
-			log.Fatal(err)
-		}
+		for _, type_name := range []string{"submission_action", "verifier_action"} {
+			dt, err := conn.LoadType(ctx, type_name)
+			if err != nil {
+				log.Fatal(err)
+			}
 
-		conn.TypeMap().RegisterType(dt)
+			conn.TypeMap().RegisterType(dt)
+		}
 
 		return nil
 	}
@@ -111,34 +231,65 @@ func NewDBConn(ctx context.Context, connURL string) DB {
 	return DB{db}
 }
 
-func (db DB) newLeaderboard(ctx context.Context, user_id string, config LeaderboardConfig) (uuid.UUID, error) {
+// newLeaderboard creates the leaderboard with its first period, which ends at
+// first_stop for recurring leaderboards.
+func (db DB) newLeaderboard(ctx context.Context, user_id string, config LeaderboardConfig, first_stop *time.Time) (uuid.UUID, LeaderboardShortID, error) {
 	var leaderboard_id uuid.UUID
+	var short_id LeaderboardShortID
 	err := db.conn.QueryRow(ctx, `
 		WITH ins_leaderboard AS (
-			INSERT INTO leaderboards(created_by, title, highest_first, is_time, start, stop, needs_verification) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-			RETURNING id
+			INSERT INTO leaderboards(created_by, title, highest_first, is_time, start, stop, needs_verification, ranking_mode, tie_policy, score_precision, recurrence, timezone, visibility, entry, grace_period) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE(NULLIF($8, ''), 'all'), COALESCE(NULLIF($9, ''), 'standard'), COALESCE($10, CASE WHEN $4 THEN 3 ELSE 0 END), NULLIF($11, ''), COALESCE(NULLIF($12, ''), 'UTC'), COALESCE(NULLIF($14, ''), 'public'), COALESCE(NULLIF($15, ''), 'open'), $16)
+			RETURNING id, seq
+		), ins_period AS (
+			INSERT INTO leaderboard_periods(leaderboard, number, stop)
+			SELECT id, 1, $13
+			FROM ins_leaderboard
+		), ins_verifier_update AS (
+			INSERT INTO verifier_updates(leaderboard, userid, author, action)
+			SELECT id, $1, $1, 'add'
+			FROM ins_leaderboard
 		)
 		INSERT INTO verifiers(leaderboard, userid)
 		SELECT id, $1
 		FROM ins_leaderboard
-		RETURNING verifiers.leaderboard
-		`, user_id, config.Title, config.HighestFirst, config.IsTime, config.Start, config.Stop, config.NeedsVerify).Scan(&leaderboard_id)
+		RETURNING verifiers.leaderboard, (SELECT seq FROM ins_leaderboard)
+		`, user_id, config.Title, config.HighestFirst, config.IsTime, config.Start, config.Stop, config.NeedsVerify, config.RankingMode, config.TiePolicy, config.Precision, config.Recurrence, config.Timezone, first_stop, config.Visibility, config.Entry, config.GracePeriod).Scan(&leaderboard_id, &short_id)
 
-	return leaderboard_id, err
+	return leaderboard_id, short_id, err
 }
 
-func (db DB) getSubmissionHistory(ctx context.Context, submission uuid.UUID) ([]HistoryEntry, error) {
+// getSubmissionHistory returns the history of a submission on the
+// leaderboard. It's pgx.ErrNoRows if the submission isn't on the leaderboard.
+func (db DB) getSubmissionHistory(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID, after *Cursor, limit int) ([]HistoryEntry, error) {
+	var exists bool
+	err := db.conn.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM submissions WHERE leaderboard=$1 AND id=$2)
+		`, leaderboard, submission).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, pgx.ErrNoRows
+	}
+
+	_, after_time, after_id := after.keyset()
 	rows, err := db.conn.Query(ctx, `
-		SELECT "user".id, "user".name, submission_updates.created_at, comment, action
+		SELECT submission_updates.id, "user".id, "user".name, submission_updates.created_at, comment, action,
+			submission_updates.previous_score, submission_updates.score, submission_updates.previous_link, submission_updates.link
 		FROM submission_updates
+		JOIN submissions
+		ON submissions.id=submission_updates.submission
 		LEFT JOIN "user"
 		ON "user".id=submission_updates.author
-		WHERE submission_updates.submission=$1
+		WHERE submissions.leaderboard=$1
+			AND submission_updates.submission=$2
+			AND ($3::timestamp IS NULL OR (submission_updates.created_at, submission_updates.id) < ($3::timestamp, $4::text::uuid))
 		ORDER BY 
-			submission_updates.created_at DESC
-		LIMIT 25
-		`, submission)
+			submission_updates.created_at DESC,
+			submission_updates.id DESC
+		LIMIT $5
+		`, leaderboard, submission, after_time, after_id, limit)
 
 	if err != nil {
 		return nil, err
@@ -149,7 +300,8 @@ func (db DB) getSubmissionHistory(ctx context.Context, submission uuid.UUID) ([]
 	for rows.Next() {
 		var entry HistoryEntry
 		var author User
-		if err := rows.Scan(&author.ID, &author.Username, &entry.TimeSubmitted, &entry.Comment, &entry.Action); err != nil {
+		if err := rows.Scan(&entry.ID, &author.ID, &author.Username, &entry.TimeSubmitted, &entry.Comment, &entry.Action,
+			&entry.PreviousScore, &entry.Score, &entry.PreviousLink, &entry.Link); err != nil {
 			return nil, err
 		}
 		entry.Author = author
@@ -161,97 +313,173 @@ func (db DB) getSubmissionHistory(ctx context.Context, submission uuid.UUID) ([]
 	return history, err
 
 }
-func (db DB) addSubmissionComment(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID, author string, comment string) error {
 
-	_, err := db.conn.Exec(ctx, `
-		INSERT INTO submission_updates(submission, author, comment, action)
-		SELECT $2, $3, $4, 'comment'
-		WHERE ((EXISTS(SELECT 1 FROM leaderboards WHERE leaderboards.id=$1 AND leaderboards.needs_verification IS TRUE)
-				AND EXISTS(SELECT 1 FROM verifiers WHERE verifiers.leaderboard=$1 AND verifiers.userid=$3)) 
-			OR EXISTS(SELECT 1 FROM leaderboards WHERE leaderboards.id=$1 AND leaderboards.needs_verification IS FALSE)
-			);
-		`, leaderboard, submission, author, comment)
+// addSubmissionComment returns the number of comments added, which is 0 if
+// the author can't comment on the submission. It's pgx.ErrNoRows if the
+// submission isn't on the leaderboard.
+func (db DB) addSubmissionComment(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID, author string, comment string) (int64, error) {
+	var count int64
+	err := db.conn.QueryRow(ctx, `
+		WITH target AS (
+			SELECT id
+			FROM submissions
+			WHERE leaderboard=$1 AND id=$2
+		), ins_comment AS (
+			INSERT INTO submission_updates(submission, author, comment, action)
+			SELECT target.id, $3, $4, 'comment'
+			FROM target
+			WHERE ((EXISTS(SELECT 1 FROM leaderboards WHERE leaderboards.id=$1 AND leaderboards.needs_verification IS TRUE)
+					AND EXISTS(SELECT 1 FROM verifiers WHERE verifiers.leaderboard=$1 AND verifiers.userid=$3)) 
+				OR EXISTS(SELECT 1 FROM leaderboards WHERE leaderboards.id=$1 AND leaderboards.needs_verification IS FALSE)
+				)
+			RETURNING id
+		)
+		SELECT (SELECT COUNT(*) FROM ins_comment)
+		FROM target;
+		`, leaderboard, submission, author, comment).Scan(&count)
 
-	if err != nil {
-		log.Println(err)
-	}
-	return err
+	return count, err
 }
 
+// verifyScore returns the number of submissions verified, which is 0 if the
+// author can't verify them. It's pgx.ErrNoRows if the submission isn't on the
+// leaderboard.
 func (db DB) verifyScore(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID, author string, is_valid bool, comment string) (int64, error) {
-	result, err := db.conn.Exec(ctx, `
-		WITH insert_history AS (
+	var count int64
+	err := db.conn.QueryRow(ctx, `
+		WITH target AS (
+			SELECT id
+			FROM submissions
+			WHERE leaderboard=$1 AND id=$2
+		), updated AS (
+			UPDATE submissions
+			SET verified=$4
+			FROM target
+			WHERE submissions.id=target.id
+				AND (
+					(EXISTS(SELECT 1 FROM leaderboards WHERE leaderboards.id=$1 AND leaderboards.needs_verification IS TRUE)
+						AND EXISTS(SELECT 1 FROM verifiers WHERE verifiers.leaderboard=$1 AND verifiers.userid=$3)) 
+					OR EXISTS(SELECT 1 FROM leaderboards WHERE leaderboards.id=$1 AND leaderboards.needs_verification IS FALSE)
+				)
+			RETURNING submissions.id
+		), insert_history AS (
 			INSERT INTO submission_updates(submission, author, comment, action)
-			VALUES ($2, $3, $5, CAST(CASE WHEN $4 Then 'validate' ELSE 'invalidate' END AS submission_action))
+			SELECT id, $3, $5, CAST(CASE WHEN $4 Then 'validate' ELSE 'invalidate' END AS submission_action)
+			FROM updated
 		)
-		UPDATE submissions
-		SET verified=$4
-		WHERE submissions.leaderboard=$1
-			AND submissions.id=$2 
-			AND (
-				(EXISTS(SELECT 1 FROM leaderboards WHERE leaderboards.id=$1 AND leaderboards.needs_verification IS TRUE)
-					AND EXISTS(SELECT 1 FROM verifiers WHERE verifiers.leaderboard=$1 AND verifiers.userid=$3)) 
-				OR EXISTS(SELECT 1 FROM leaderboards WHERE leaderboards.id=$1 AND leaderboards.needs_verification IS FALSE)
-			);
-		`, leaderboard, submission, author, is_valid, comment)
-
-	if err != nil {
-		log.Println(err)
-	}
-	return result.RowsAffected(), nil
+		SELECT (SELECT COUNT(*) FROM updated)
+		FROM target;
+		`, leaderboard, submission, author, is_valid, comment).Scan(&count)
+	return count, err
 }
 
 func (db DB) getSubmissionInfo(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID) (DetailedSubmission, error) {
 	var submissionInfo DetailedSubmission
 	var submitter User
+	var is_time bool
+	var precision int
 	err := db.conn.QueryRow(ctx, `
-		SELECT submissions.created_at, submissions.score, submissions.link, submissions.leaderboard, leaderboards.title, "user".name, "user".id, submissions.verified
+		SELECT submissions.id, submissions.seq, submissions.created_at, submissions.score, submissions.link, leaderboards.seq, leaderboards.title, "user".name, "user".id, submissions.verified, submissions.withdrawn_at, leaderboards.is_time, leaderboards.score_precision
 		FROM submissions
 		LEFT JOIN leaderboards
 		ON leaderboards.id=submissions.leaderboard
 		LEFT JOIN "user"
 		ON "user".id=submissions.userid
 		WHERE submissions.leaderboard=$1 AND submissions.id=$2
-		`, leaderboard, submission).Scan(&submissionInfo.TimeCreated,
+		`, leaderboard, submission).Scan(&submissionInfo.UUID,
+		&submissionInfo.ID,
+		&submissionInfo.TimeCreated,
 		&submissionInfo.Score,
 		&submissionInfo.Link,
 		&submissionInfo.LeaderboardID,
 		&submissionInfo.LeaderboardDisplayName,
 		&submitter.Username,
 		&submitter.ID,
-		&submissionInfo.Verified)
+		&submissionInfo.Verified,
+		&submissionInfo.TimeWithdrawn,
+		&is_time,
+		&precision)
 	if err != nil {
 		return submissionInfo, err
 	}
 	submissionInfo.Submitter = &submitter
+	submissionInfo.DisplayScore = submissionInfo.Score.Format(is_time, precision)
 	return submissionInfo, nil
 }
 
-func (db DB) newSubmission(ctx context.Context, leaderboard uuid.UUID, user string, score int, link string) (uuid.UUID, error) {
+func (db DB) newSubmission(ctx context.Context, leaderboard uuid.UUID, user string, score Score, link string) (uuid.UUID, SubmissionShortID, error) {
 	var submission_id uuid.UUID
+	var short_id SubmissionShortID
 	err := db.conn.QueryRow(ctx, `
 		INSERT INTO submissions (leaderboard, userid, score, link)
 		VALUES ($1, $2, $3, $4)
-		RETURNING id
-		`, leaderboard, user, score, link).Scan(&submission_id)
+		RETURNING id, seq
+		`, leaderboard, user, score, link).Scan(&submission_id, &short_id)
+	return submission_id, short_id, err
+}
+
+func (db DB) getSubmissionOwner(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID) (string, error) {
+	var owner string
+	err := db.conn.QueryRow(ctx, `
+		SELECT userid
+		FROM submissions
+		WHERE leaderboard=$1 AND id=$2 AND withdrawn_at IS NULL
+		`, leaderboard, submission).Scan(&owner)
+
+	return owner, err
+}
+
+func (db DB) updateSubmissionScore(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID, author string, score *Score, link *string, comment string) (uuid.UUID, error) {
+	var submission_id uuid.UUID
+
+	err := db.conn.QueryRow(ctx, `
+		WITH previous AS (
+			SELECT id, score, link
+			FROM submissions
+			WHERE leaderboard=$1 AND id=$2 AND userid=$3 AND withdrawn_at IS NULL
+			FOR UPDATE
+		), updated AS (
+			UPDATE submissions
+			SET
+				score=COALESCE($4, submissions.score),
+				link=COALESCE($5, submissions.link),
+				verified=FALSE,
+				last_updated=NOW()
+			FROM previous
+			WHERE submissions.id=previous.id
+			RETURNING submissions.id, submissions.score, submissions.link
+		)
+		INSERT INTO submission_updates(submission, author, comment, action, previous_score, score, previous_link, link)
+		SELECT updated.id, $3, $6, 'edit', previous.score, updated.score, previous.link, updated.link
+		FROM updated
+		JOIN previous
+		ON previous.id=updated.id
+		RETURNING submission;
+		`, leaderboard, submission, author, score, link, comment).Scan(&submission_id)
+
 	if err != nil {
-		log.Println(err)
+		return uuid.UUID{}, err
 	}
 	return submission_id, nil
 }
 
-func (db DB) updateSubmissionScore(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID, score int, link string) (uuid.UUID, error) {
+func (db DB) withdrawSubmission(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID, author string) (uuid.UUID, error) {
 	var submission_id uuid.UUID
 
 	err := db.conn.QueryRow(ctx, `
-		UPDATE submissions
-		SET
-			score=$3,
-			link=$4,
-			verified=FALSE
-		WHERE leaderboard=$1 AND id=$2
-		RETURNING id;
-		`, leaderboard, submission, score, link).Scan(&submission_id)
+		WITH withdrawn AS (
+			UPDATE submissions
+			SET
+				withdrawn_at=NOW(),
+				last_updated=NOW()
+			WHERE leaderboard=$1 AND id=$2 AND userid=$3 AND withdrawn_at IS NULL
+			RETURNING id
+		)
+		INSERT INTO submission_updates(submission, author, comment, action)
+		SELECT id, $3, '', 'withdraw'
+		FROM withdrawn
+		RETURNING submission;
+		`, leaderboard, submission, author).Scan(&submission_id)
 
 	if err != nil {
 		return uuid.UUID{}, err
@@ -262,28 +490,147 @@ func (db DB) updateSubmissionScore(ctx context.Context, leaderboard uuid.UUID, s
 func (db DB) getLeaderboardInfo(ctx context.Context, leaderboard uuid.UUID) (LeaderboardInfo, error) {
 	var info LeaderboardInfo
 	err := db.conn.QueryRow(ctx, `
-		SELECT title, start, stop, is_time, needs_verification, highest_first, created_at
+		SELECT leaderboards.id, leaderboards.seq, title, leaderboards.start, leaderboards.stop, is_time, needs_verification, highest_first, ranking_mode, tie_policy, score_precision, COALESCE(recurrence, ''), timezone, visibility, entry, grace_period, created_at, archived_at,
+			submission_secret IS NOT NULL, slug, current_period.number, current_period.start, current_period.stop
 		FROM leaderboards 
-		WHERE id=$1;
-		`, leaderboard).Scan(&info.Title, &info.LeaderboardConfig.Start, &info.Stop, &info.IsTime, &info.NeedsVerify, &info.HighestFirst, &info.TimeCreated)
+		JOIN leaderboard_periods current_period
+		ON current_period.leaderboard=leaderboards.id
+		WHERE leaderboards.id=$1 AND deleted_at IS NULL
+		ORDER BY current_period.number DESC
+		LIMIT 1;
+		`, leaderboard).Scan(&info.UUID, &info.ID, &info.Title, &info.LeaderboardConfig.Start, &info.Stop, &info.IsTime, &info.NeedsVerify, &info.HighestFirst, &info.RankingMode, &info.TiePolicy, &info.Precision, &info.Recurrence, &info.Timezone, &info.Visibility, &info.Entry, &info.GracePeriod, &info.TimeCreated, &info.TimeArchived,
+		&info.SignedScores, &info.Slug, &info.CurrentPeriod.Number, &info.CurrentPeriod.Start, &info.CurrentPeriod.Stop)
 
 	if err != nil {
 		return info, err
 	}
+	info.State = info.stateAt(time.Now())
 	return info, nil
 }
 
-func (db DB) getVerifiers(ctx context.Context, leaderboard_id uuid.UUID) ([]User, error) {
+func (info LeaderboardInfo) stateAt(now time.Time) string {
+	return ScoreRules{
+		Start:        info.LeaderboardConfig.Start,
+		Stop:         info.Stop,
+		GracePeriod:  info.GracePeriod,
+		TimeArchived: info.TimeArchived,
+	}.stateAt(now)
+}
+
+// ScoreRules holds the settings new and edited scores are checked against.
+type ScoreRules struct {
+	IsTime       bool
+	Precision    int
+	Start        time.Time
+	Stop         *time.Time
+	GracePeriod  int
+	TimeArchived *time.Time
+}
+
+// stateAt returns whether the leaderboard is upcoming, open or closed at now.
+// It stays open for its grace period after stop.
+func (rules ScoreRules) stateAt(now time.Time) string {
+	switch {
+	case rules.TimeArchived != nil:
+		return "closed"
+	case now.Before(rules.Start):
+		return "upcoming"
+	case rules.Stop != nil && !now.Before(rules.Stop.Add(time.Duration(rules.GracePeriod)*time.Second)):
+		return "closed"
+	}
+	return "open"
+}
+
+func (db DB) getScoreRules(ctx context.Context, leaderboard uuid.UUID) (ScoreRules, error) {
+	var rules ScoreRules
+	err := db.conn.QueryRow(ctx, `
+		SELECT is_time, score_precision, start, stop, grace_period, archived_at
+		FROM leaderboards
+		WHERE id=$1 AND deleted_at IS NULL
+		`, leaderboard).Scan(&rules.IsTime, &rules.Precision, &rules.Start, &rules.Stop, &rules.GracePeriod, &rules.TimeArchived)
+
+	return rules, err
+}
+
+func (db DB) getLeaderboardOwner(ctx context.Context, leaderboard uuid.UUID) (string, error) {
+	var owner string
+	err := db.conn.QueryRow(ctx, `
+		SELECT created_by
+		FROM leaderboards
+		WHERE id=$1 AND deleted_at IS NULL
+		`, leaderboard).Scan(&owner)
+
+	return owner, err
+}
+
+func (db DB) updateLeaderboard(ctx context.Context, leaderboard uuid.UUID, update LeaderboardUpdate) error {
+	_, err := db.conn.Exec(ctx, `
+		UPDATE leaderboards
+		SET
+			title=COALESCE($2, title),
+			highest_first=COALESCE($3, highest_first),
+			needs_verification=COALESCE($4, needs_verification),
+			stop=CASE WHEN $12 THEN $5 ELSE stop END,
+			ranking_mode=COALESCE($6, ranking_mode),
+			tie_policy=COALESCE($7, tie_policy),
+			score_precision=COALESCE($8, score_precision),
+			visibility=COALESCE($9, visibility),
+			entry=COALESCE($10, entry),
+			grace_period=COALESCE($11, grace_period),
+			-- Moving stop past a close that was already notified notifies
+			-- the new close when it comes.
+			close_notified_at=CASE
+				WHEN $12 AND ($5::timestamp IS NULL OR $5::timestamp + COALESCE($11, grace_period) * INTERVAL '1 second' > NOW()) THEN NULL
+				ELSE close_notified_at
+			END,
+			last_updated=NOW()
+		WHERE id=$1 AND deleted_at IS NULL
+		`, leaderboard, update.Title, update.HighestFirst, update.NeedsVerify, update.Stop.Time, update.RankingMode, update.TiePolicy, update.Precision, update.Visibility, update.Entry, update.GracePeriod, update.Stop.Set)
+
+	return err
+}
+
+// archiveLeaderboard closes an open leaderboard immediately and stops it
+// counting towards the creator's active leaderboard limit. One that hasn't
+// started yet is moved to start and stop now, so it never opens.
+func (db DB) archiveLeaderboard(ctx context.Context, leaderboard uuid.UUID) (int64, error) {
+	result, err := db.conn.Exec(ctx, `
+		UPDATE leaderboards
+		SET
+			archived_at=NOW(),
+			start=LEAST(start, NOW()),
+			stop=CASE WHEN stop IS NULL OR stop > NOW() THEN NOW() ELSE stop END,
+			last_updated=NOW()
+		WHERE id=$1 AND deleted_at IS NULL AND archived_at IS NULL
+		`, leaderboard)
+
+	return result.RowsAffected(), err
+}
+
+func (db DB) deleteLeaderboard(ctx context.Context, leaderboard uuid.UUID) error {
+	_, err := db.conn.Exec(ctx, `
+		UPDATE leaderboards
+		SET deleted_at=NOW()
+		WHERE id=$1 AND deleted_at IS NULL
+		`, leaderboard)
+
+	return err
+}
+
+func (db DB) getVerifiers(ctx context.Context, leaderboard_id uuid.UUID, after *Cursor, limit int) ([]User, error) {
+	_, after_time, after_id := after.keyset()
 	rows, err := db.conn.Query(ctx, `
-		SELECT "user".id, "user".name, verifiers.added_at
+		SELECT verifiers.userid, "user".name, verifiers.added_at
 		FROM verifiers
 		LEFT JOIN "user"
 		ON "user".id=verifiers.userid
 		WHERE leaderboard=$1
+			AND ($2::timestamp IS NULL OR (verifiers.added_at, verifiers.userid) < ($2::timestamp, $3::text))
 		ORDER BY 
-			added_at DESC
-		LIMIT 25
-		`, leaderboard_id)
+			added_at DESC,
+			verifiers.userid DESC
+		LIMIT $4
+		`, leaderboard_id, after_time, after_id, limit)
 
 	if err != nil {
 		return nil, err
@@ -304,15 +651,198 @@ func (db DB) getVerifiers(ctx context.Context, leaderboard_id uuid.UUID) ([]User
 	return owners, err
 }
 
-func (db DB) getAccountLeaderboards(ctx context.Context, user_id string) ([]LeaderboardInfo, error) {
+// findUser looks up a user by user_id, or by their unique username if no
+// user_id is given.
+func (db DB) findUser(ctx context.Context, user_id string, username string) (User, error) {
+	var user User
+	err := db.conn.QueryRow(ctx, `
+		SELECT id, name
+		FROM "user"
+		WHERE CASE WHEN $1 <> '' THEN id=$1 ELSE username=$2 END
+		`, user_id, username).Scan(&user.ID, &user.Username)
+
+	return user, err
+}
+
+// isVerifier reports whether user_id created or verifies the leaderboard.
+func (db DB) isVerifier(ctx context.Context, leaderboard uuid.UUID, user_id string) (bool, error) {
+	var verifier bool
+	err := db.conn.QueryRow(ctx, `
+		SELECT created_by=$2 OR EXISTS(SELECT 1 FROM verifiers WHERE leaderboard=$1 AND userid=$2)
+		FROM leaderboards
+		WHERE id=$1 AND deleted_at IS NULL
+		`, leaderboard, user_id).Scan(&verifier)
+
+	return verifier, err
+}
+
+func (db DB) addVerifier(ctx context.Context, leaderboard_id uuid.UUID, user_id string, author string) error {
+	_, err := db.conn.Exec(ctx, `
+		WITH ins_verifier AS (
+			INSERT INTO verifiers(leaderboard, userid)
+			VALUES ($1, $2)
+			RETURNING leaderboard, userid
+		)
+		INSERT INTO verifier_updates(leaderboard, userid, author, action)
+		SELECT leaderboard, userid, $3, 'add'
+		FROM ins_verifier
+		`, leaderboard_id, user_id, author)
+
+	return err
+}
+
+func (db DB) removeVerifier(ctx context.Context, leaderboard_id uuid.UUID, user_id string, author string) (int64, error) {
+	result, err := db.conn.Exec(ctx, `
+		WITH del_verifier AS (
+			DELETE FROM verifiers
+			WHERE leaderboard=$1 AND userid=$2
+			RETURNING leaderboard, userid
+		)
+		INSERT INTO verifier_updates(leaderboard, userid, author, action)
+		SELECT leaderboard, userid, $3, 'remove'
+		FROM del_verifier
+		`, leaderboard_id, user_id, author)
+
+	return result.RowsAffected(), err
+}
+
+// canSubmit reports whether user_id may submit to the leaderboard under its
+// entry setting.
+func (db DB) canSubmit(ctx context.Context, leaderboard uuid.UUID, user_id string) (bool, error) {
+	var allowed bool
+	err := db.conn.QueryRow(ctx, `
+		SELECT entry='open' OR created_by=$2
+			OR EXISTS(SELECT 1 FROM verifiers WHERE leaderboard=$1 AND userid=$2)
+			OR EXISTS(SELECT 1 FROM leaderboard_members WHERE leaderboard=$1 AND userid=$2)
+		FROM leaderboards
+		WHERE id=$1 AND deleted_at IS NULL
+		`, leaderboard, user_id).Scan(&allowed)
+
+	return allowed, err
+}
+
+// canViewLeaderboard reports whether user_id, which is empty for anonymous
+// requests, may see the leaderboard under its visibility. Short IDs are
+// sequential and so can be guessed, which makes unlisted leaderboards looked
+// up by_short_id as hidden as private ones.
+func (db DB) canViewLeaderboard(ctx context.Context, leaderboard uuid.UUID, user_id string, by_short_id bool) (bool, error) {
+	var allowed bool
+	err := db.conn.QueryRow(ctx, `
+		SELECT visibility='public' OR (visibility='unlisted' AND NOT $3) OR created_by=$2
+			OR EXISTS(SELECT 1 FROM verifiers WHERE leaderboard=$1 AND userid=$2)
+			OR EXISTS(SELECT 1 FROM leaderboard_members WHERE leaderboard=$1 AND userid=$2)
+		FROM leaderboards
+		WHERE id=$1 AND deleted_at IS NULL
+		`, leaderboard, user_id, by_short_id).Scan(&allowed)
+
+	return allowed, err
+}
+
+func (db DB) getMembers(ctx context.Context, leaderboard_id uuid.UUID, after *Cursor, limit int) ([]User, error) {
+	_, after_time, after_id := after.keyset()
+	rows, err := db.conn.Query(ctx, `
+		SELECT leaderboard_members.userid, "user".name, leaderboard_members.added_at
+		FROM leaderboard_members
+		LEFT JOIN "user"
+		ON "user".id=leaderboard_members.userid
+		WHERE leaderboard=$1
+			AND ($2::timestamp IS NULL OR (leaderboard_members.added_at, leaderboard_members.userid) < ($2::timestamp, $3::text))
+		ORDER BY 
+			added_at DESC,
+			leaderboard_members.userid DESC
+		LIMIT $4
+		`, leaderboard_id, after_time, after_id, limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := []User{}
+
+	for rows.Next() {
+		var member User
+		if err := rows.Scan(&member.ID, &member.Username, &member.TimeAdded); err != nil {
+			return members, err
+		}
+		members = append(members, member)
+	}
+	if err = rows.Err(); err != nil {
+		return members, err
+	}
+	return members, err
+}
+
+// addMember returns 0 if the user is already a member.
+func (db DB) addMember(ctx context.Context, leaderboard_id uuid.UUID, user_id string, author string) (int64, error) {
+	result, err := db.conn.Exec(ctx, `
+		INSERT INTO leaderboard_members(leaderboard, userid, added_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		`, leaderboard_id, user_id, author)
+
+	return result.RowsAffected(), err
+}
+
+func (db DB) removeMember(ctx context.Context, leaderboard_id uuid.UUID, user_id string) (int64, error) {
+	result, err := db.conn.Exec(ctx, `
+		DELETE FROM leaderboard_members
+		WHERE leaderboard=$1 AND userid=$2
+		`, leaderboard_id, user_id)
+
+	return result.RowsAffected(), err
+}
+
+func (db DB) getVerifierHistory(ctx context.Context, leaderboard_id uuid.UUID, after *Cursor, limit int) ([]VerifierHistoryEntry, error) {
+	_, after_time, after_id := after.keyset()
+	rows, err := db.conn.Query(ctx, `
+		SELECT verifier_updates.id, verifier.id, verifier.name, author.id, author.name, verifier_updates.action, verifier_updates.created_at
+		FROM verifier_updates
+		LEFT JOIN "user" verifier
+		ON verifier.id=verifier_updates.userid
+		LEFT JOIN "user" author
+		ON author.id=verifier_updates.author
+		WHERE verifier_updates.leaderboard=$1
+			AND ($2::timestamp IS NULL OR (verifier_updates.created_at, verifier_updates.id) < ($2::timestamp, $3::text::uuid))
+		ORDER BY 
+			verifier_updates.created_at DESC,
+			verifier_updates.id DESC
+		LIMIT $4
+		`, leaderboard_id, after_time, after_id, limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := []VerifierHistoryEntry{}
+
+	for rows.Next() {
+		var entry VerifierHistoryEntry
+		if err := rows.Scan(&entry.ID, &entry.Verifier.ID, &entry.Verifier.Username, &entry.Author.ID, &entry.Author.Username, &entry.Action, &entry.TimeChanged); err != nil {
+			return history, err
+		}
+		history = append(history, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return history, err
+	}
+	return history, err
+}
+
+// getAccountLeaderboards lists the leaderboards user_id created. Only public
+// ones are included unless include_hidden is set.
+func (db DB) getAccountLeaderboards(ctx context.Context, user_id string, include_hidden bool, after *Cursor, limit int) ([]LeaderboardInfo, error) {
+	_, after_time, after_id := after.keyset()
 	rows, err := db.conn.Query(ctx, `
-		SELECT id, title, created_at, start, stop
+		SELECT id, seq, title, created_at, start, stop, archived_at, visibility, grace_period
 		FROM leaderboards
-		WHERE created_by=$1
+		WHERE created_by=$1 AND deleted_at IS NULL
+			AND ($5 OR visibility='public')
+			AND ($2::timestamp IS NULL OR (created_at, id) < ($2::timestamp, $3::text::uuid))
 		ORDER BY 
-			created_at DESC
-		LIMIT 25
-		`, user_id)
+			created_at DESC,
+			id DESC
+		LIMIT $4
+		`, user_id, after_time, after_id, limit, include_hidden)
 
 	if err != nil {
 		return nil, err
@@ -322,9 +852,10 @@ func (db DB) getAccountLeaderboards(ctx context.Context, user_id string) ([]Lead
 
 	for rows.Next() {
 		var li LeaderboardInfo
-		if err := rows.Scan(&li.ID, &li.Title, &li.TimeCreated, &li.Start, &li.Stop); err != nil {
+		if err := rows.Scan(&li.UUID, &li.ID, &li.Title, &li.TimeCreated, &li.Start, &li.Stop, &li.TimeArchived, &li.Visibility, &li.GracePeriod); err != nil {
 			return leaderboards, err
 		}
+		li.State = li.stateAt(time.Now())
 		leaderboards = append(leaderboards, li)
 	}
 	if err = rows.Err(); err != nil {
@@ -333,17 +864,23 @@ func (db DB) getAccountLeaderboards(ctx context.Context, user_id string) ([]Lead
 	return leaderboards, err
 }
 
-func (db DB) getAccountSubmissions(ctx context.Context, user_id string) ([]DetailedSubmission, error) {
+// getAccountSubmissions lists user_id's submissions. Only those on public
+// leaderboards are included unless include_hidden is set.
+func (db DB) getAccountSubmissions(ctx context.Context, user_id string, include_hidden bool, after *Cursor, limit int) ([]DetailedSubmission, error) {
+	_, after_time, after_id := after.keyset()
 	rows, err := db.conn.Query(ctx, `
-		SELECT submissions.id, leaderboards.title, submissions.created_at, submissions.score, leaderboards.id
+		SELECT submissions.id, submissions.seq, leaderboards.title, submissions.created_at, submissions.score, leaderboards.seq, leaderboards.is_time, leaderboards.score_precision
 		FROM submissions
 		LEFT JOIN leaderboards
 		ON submissions.leaderboard=leaderboards.id
-		WHERE submissions.userid=$1
+		WHERE submissions.userid=$1 AND submissions.withdrawn_at IS NULL
+			AND ($5 OR leaderboards.visibility='public')
+			AND ($2::timestamp IS NULL OR (submissions.created_at, submissions.id) < ($2::timestamp, $3::text::uuid))
 		ORDER BY 
-			created_at DESC
-		LIMIT 25
-		`, user_id)
+			submissions.created_at DESC,
+			submissions.id DESC
+		LIMIT $4
+		`, user_id, after_time, after_id, limit, include_hidden)
 
 	if err != nil {
 		return nil, err
@@ -353,9 +890,12 @@ func (db DB) getAccountSubmissions(ctx context.Context, user_id string) ([]Detai
 
 	for rows.Next() {
 		var s DetailedSubmission
-		if err := rows.Scan(&s.ID, &s.LeaderboardDisplayName, &s.TimeCreated, &s.Score, &s.LeaderboardID); err != nil {
+		var is_time bool
+		var precision int
+		if err := rows.Scan(&s.UUID, &s.ID, &s.LeaderboardDisplayName, &s.TimeCreated, &s.Score, &s.LeaderboardID, &is_time, &precision); err != nil {
 			return submissions, err
 		}
+		s.DisplayScore = s.Score.Format(is_time, precision)
 		submissions = append(submissions, s)
 	}
 	if err = rows.Err(); err != nil {
@@ -364,50 +904,246 @@ func (db DB) getAccountSubmissions(ctx context.Context, user_id string) ([]Detai
 	return submissions, err
 }
 
-func (db DB) getLeaderboard(ctx context.Context, leaderboard uuid.UUID) ([]Ranking, error) {
-	rows, err := db.conn.Query(ctx, `
-		WITH leaderboard_config(cutoff, highest_first, needs_verification) AS (
-			SELECT
-				CASE WHEN stop is NULL THEN NULL
-				ELSE stop
-				END, highest_first, needs_verification
-			FROM leaderboards
-			WHERE id=$1
-		)
-		SELECT submissions.userid, submissions.score, submissions.created_at, (CASE WHEN leaderboard_config.needs_verification THEN submissions.verified ELSE NULL END), submissions.id, "user".name
-		FROM 
-			(submissions LEFT JOIN "user"
-				ON "user".id = submissions.userid), 
-			leaderboard_config
-		WHERE submissions.leaderboard=$1 AND (leaderboard_config.cutoff > submissions.created_at OR leaderboard_config.cutoff is NULL)
-		ORDER BY 
-			(CASE WHEN leaderboard_config.highest_first THEN submissions.score END) DESC,
-			submissions.score ASC,
-			submissions.created_at DESC
-		LIMIT 100
-		`, leaderboard)
-
-	if err != nil {
-		return nil, err
-	}
+// ranked_submissions defines the leaderboard_config and ranked CTEs shared by
+// the ranking queries, for the leaderboard passed as $1 and the period number
+// passed as $2, or the current period if $2 is NULL. ranked holds the
+// submissions counted under the leaderboard's ranking mode, each with its rank
+// under the tie policy, its position in display order, and the total count.
+const ranked_submissions = `
+	leaderboard_period(start, stop) AS (
+		-- The current period stays open until the scheduler starts the next
+		-- one, so submissions made in between aren't lost.
+		SELECT start, CASE WHEN number = (SELECT MAX(number) FROM leaderboard_periods WHERE leaderboard=$1) THEN NULL ELSE stop END
+		FROM leaderboard_periods
+		WHERE leaderboard=$1 AND ($2::int IS NULL OR number=$2)
+		ORDER BY number DESC
+		LIMIT 1
+	), leaderboard_config(cutoff, highest_first, needs_verification, ranking_mode, tie_policy, is_time, score_precision, period_start, period_stop) AS (
+		SELECT
+			leaderboards.stop + grace_period * INTERVAL '1 second', highest_first, needs_verification, ranking_mode, tie_policy, is_time, score_precision, leaderboard_period.start, leaderboard_period.stop
+		FROM leaderboards, leaderboard_period
+		WHERE id=$1 AND deleted_at IS NULL
+	), eligible AS (
+		-- user_position orders each user's submissions by the leaderboard's
+		-- ranking mode, so the user's ranked submission is at position 1.
+		SELECT submissions.*,
+			ROW_NUMBER() OVER (
+				PARTITION BY submissions.userid
+				ORDER BY
+					(CASE WHEN leaderboard_config.ranking_mode = 'latest' THEN submissions.created_at END) DESC,
+					(CASE WHEN leaderboard_config.highest_first THEN submissions.score END) DESC,
+					submissions.score ASC,
+					submissions.created_at ASC,
+					submissions.id ASC
+			) AS user_position
+		FROM submissions, leaderboard_config
+		WHERE submissions.leaderboard=$1
+			AND submissions.withdrawn_at IS NULL
+			AND (leaderboard_config.cutoff > submissions.created_at OR leaderboard_config.cutoff is NULL)
+			AND (leaderboard_config.period_start <= submissions.created_at OR leaderboard_config.period_start is NULL)
+			AND (leaderboard_config.period_stop > submissions.created_at OR leaderboard_config.period_stop is NULL)
+	), ranked AS (
+		-- Ranks are computed over the whole leaderboard before paging, so
+		-- they stay the same on every page.
+		SELECT eligible.*,
+			CASE leaderboard_config.tie_policy
+				WHEN 'dense' THEN DENSE_RANK() OVER by_score
+				WHEN 'earliest' THEN ROW_NUMBER() OVER by_submission
+				ELSE RANK() OVER by_score
+			END AS rank,
+			ROW_NUMBER() OVER by_submission AS position,
+			COUNT(*) OVER () AS total
+		FROM eligible, leaderboard_config
+		WHERE leaderboard_config.ranking_mode = 'all' OR eligible.user_position = 1
+		WINDOW
+			by_score AS (ORDER BY (CASE WHEN leaderboard_config.highest_first THEN eligible.score END) DESC, eligible.score ASC),
+			by_submission AS (ORDER BY (CASE WHEN leaderboard_config.highest_first THEN eligible.score END) DESC, eligible.score ASC, eligible.created_at ASC, eligible.id ASC)
+	)`
+
+func collectRankings(rows pgx.Rows) ([]Ranking, error) {
 	defer rows.Close()
 	entries := []Ranking{}
 
 	for rows.Next() {
 		var e Ranking
 		var user User
-		if err := rows.Scan(&user.ID, &e.Score, &e.TimeSubmitted, &e.Verified, &e.ID, &user.Username); err != nil {
+		var is_time bool
+		var precision int
+		if err := rows.Scan(&e.Rank, &user.ID, &e.Score, &e.TimeSubmitted, &e.Verified, &e.UUID, &e.ID, &user.Username, &is_time, &precision); err != nil {
 			return entries, err
 		}
 		e.User = user
+		e.DisplayScore = e.Score.Format(is_time, precision)
 		entries = append(entries, e)
 	}
-	if err = rows.Err(); err != nil {
-		return entries, err
+	return entries, rows.Err()
+}
+
+// getLeaderboard returns rankings for the given period, or the current period if it is nil.
+func (db DB) getLeaderboard(ctx context.Context, leaderboard uuid.UUID, period *int, after *Cursor, limit int) ([]Ranking, error) {
+	after_score, after_time, after_id := after.keyset()
+	rows, err := db.conn.Query(ctx, `
+		WITH `+ranked_submissions+`
+		SELECT ranked.rank, ranked.userid, ranked.score, ranked.created_at, (CASE WHEN leaderboard_config.needs_verification THEN ranked.verified ELSE NULL END), ranked.id, ranked.seq, "user".name, leaderboard_config.is_time, leaderboard_config.score_precision
+		FROM 
+			(ranked LEFT JOIN "user"
+				ON "user".id = ranked.userid), 
+			leaderboard_config
+		WHERE $3::numeric IS NULL
+			OR (CASE WHEN leaderboard_config.highest_first THEN ranked.score < $3 ELSE ranked.score > $3 END)
+			OR (ranked.score = $3 AND (ranked.created_at > $4::timestamp
+				OR (ranked.created_at = $4::timestamp AND ranked.id > $5::text::uuid)))
+		ORDER BY ranked.position
+		LIMIT $6
+		`, leaderboard, period, after_score, after_time, after_id, limit)
+
+	if err != nil {
+		return nil, err
+	}
+	return collectRankings(rows)
+}
+
+// getUserRank returns the user's highest ranked submission in the current period.
+func (db DB) getUserRank(ctx context.Context, leaderboard uuid.UUID, user_id string) (UserRank, error) {
+	var r UserRank
+	var user User
+	var is_time bool
+	var precision int
+	var at_or_below int
+	err := db.conn.QueryRow(ctx, `
+		WITH `+ranked_submissions+`
+		SELECT ranked.rank, ranked.total, (SELECT COUNT(*) FROM ranked AS below WHERE below.rank >= ranked.rank), ranked.userid, ranked.score, ranked.created_at, (CASE WHEN leaderboard_config.needs_verification THEN ranked.verified ELSE NULL END), ranked.id, ranked.seq, "user".name, leaderboard_config.is_time, leaderboard_config.score_precision
+		FROM 
+			(ranked LEFT JOIN "user"
+				ON "user".id = ranked.userid), 
+			leaderboard_config
+		WHERE ranked.userid=$3
+		ORDER BY ranked.position
+		LIMIT 1
+		`, leaderboard, nil, user_id).Scan(&r.Rank, &r.Total, &at_or_below, &user.ID, &r.Best.Score, &r.Best.TimeSubmitted, &r.Best.Verified, &r.Best.UUID, &r.Best.ID, &user.Username, &is_time, &precision)
+	if err != nil {
+		return r, err
+	}
+	r.Best.Rank = r.Rank
+	r.Best.DisplayScore = r.Best.Score.Format(is_time, precision)
+	r.Best.User = user
+	r.User = user
+	// Share of ranked entries at or below this rank, so first place is 100.
+	// Ranks aren't positions when ties are dense, so the entries are counted.
+	r.Percentile = 100 * float64(at_or_below) / float64(r.Total)
+	return r, nil
+}
+
+// getSubmissionsAround returns the submission with up to n ranked entries on
+// either side of it. The submission must count towards the current period's
+// rankings.
+func (db DB) getSubmissionsAround(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID, n int) ([]Ranking, error) {
+	rows, err := db.conn.Query(ctx, `
+		WITH `+ranked_submissions+`, target AS (
+			SELECT position FROM ranked WHERE id=$3
+		)
+		SELECT ranked.rank, ranked.userid, ranked.score, ranked.created_at, (CASE WHEN leaderboard_config.needs_verification THEN ranked.verified ELSE NULL END), ranked.id, ranked.seq, "user".name, leaderboard_config.is_time, leaderboard_config.score_precision
+		FROM 
+			(ranked LEFT JOIN "user"
+				ON "user".id = ranked.userid), 
+			leaderboard_config, target
+		WHERE ranked.position BETWEEN target.position - $4 AND target.position + $4
+		ORDER BY ranked.position
+		`, leaderboard, nil, submission, n)
+
+	if err != nil {
+		return nil, err
+	}
+	entries, err := collectRankings(rows)
+	if err == nil && len(entries) == 0 {
+		return entries, pgx.ErrNoRows
 	}
 	return entries, err
 }
 
+func (db DB) getLeaderboardPeriod(ctx context.Context, leaderboard uuid.UUID, number int) (LeaderboardPeriod, error) {
+	var period LeaderboardPeriod
+	err := db.conn.QueryRow(ctx, `
+		SELECT leaderboard_periods.number, leaderboard_periods.start, leaderboard_periods.stop
+		FROM leaderboard_periods
+		JOIN leaderboards
+		ON leaderboards.id=leaderboard_periods.leaderboard
+		WHERE leaderboard_periods.leaderboard=$1 AND leaderboard_periods.number=$2 AND leaderboards.deleted_at IS NULL
+		`, leaderboard, number).Scan(&period.Number, &period.Start, &period.Stop)
+	return period, err
+}
+
+// rollPeriods starts the next period of recurring leaderboards whose current
+// period ended before now, returning the leaderboards that rolled over. The new
+// period begins where the last one stopped and ends at the first boundary
+// after now, so downtime doesn't leave a run of empty periods.
+func (db DB) rollPeriods(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT leaderboards.id, leaderboards.recurrence, leaderboards.timezone, current_period.number, current_period.stop
+		FROM leaderboards
+		JOIN leaderboard_periods current_period
+		ON current_period.leaderboard=leaderboards.id
+		WHERE leaderboards.recurrence IS NOT NULL
+			AND leaderboards.deleted_at IS NULL
+			AND leaderboards.archived_at IS NULL
+			AND current_period.number = (SELECT MAX(number) FROM leaderboard_periods WHERE leaderboard=leaderboards.id)
+			AND current_period.stop <= $1
+			AND (leaderboards.stop IS NULL OR leaderboards.stop > current_period.stop)
+		FOR UPDATE OF leaderboards SKIP LOCKED
+		LIMIT 100
+		`, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	type due struct {
+		id         uuid.UUID
+		recurrence string
+		timezone   string
+		number     int
+		stop       time.Time
+	}
+	due_periods, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (due, error) {
+		var d due
+		err := row.Scan(&d.id, &d.recurrence, &d.timezone, &d.number, &d.stop)
+		return d, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rolled := []uuid.UUID{}
+	for _, d := range due_periods {
+		r, err := parseRecurrence(d.recurrence, d.timezone)
+		if err != nil {
+			log.Printf("Leaderboard %s has an invalid recurrence: %v", d.id, err)
+			continue
+		}
+		next := r.Next(now)
+		if next.IsZero() {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `
+			WITH ins_period AS (
+				INSERT INTO leaderboard_periods(leaderboard, number, start, stop)
+				VALUES ($1, $2, $3, $4)
+			)
+			UPDATE leaderboards
+			SET last_updated=NOW()
+			WHERE id=$1
+			`, d.id, d.number+1, d.stop, next.UTC()); err != nil {
+			return nil, err
+		}
+		rolled = append(rolled, d.id)
+	}
+	return rolled, tx.Commit(ctx)
+}
+
 func (db DB) linkAccounts(ctx context.Context, anon_id string, user_id string) error {
 
 	tx, err := db.conn.Begin(ctx)
@@ -434,6 +1170,16 @@ func (db DB) linkAccounts(ctx context.Context, anon_id string, user_id string) e
 		return tx_err
 	}
 
+	_, tx_err = tx.Exec(ctx, `
+		UPDATE leaderboard_members
+		SET userid=$2
+		WHERE userid=$1 AND EXISTS(SELECT 1 FROM "user" WHERE id=$1 AND "isAnonymous"=TRUE)
+			AND NOT EXISTS(SELECT 1 FROM leaderboard_members existing WHERE existing.leaderboard=leaderboard_members.leaderboard AND existing.userid=$2)
+		`, anon_id, user_id)
+	if tx_err != nil {
+		return tx_err
+	}
+
 	_, tx_err = tx.Exec(ctx, `
 		UPDATE submissions
 		SET userid=$2
@@ -452,7 +1198,10 @@ func (db DB) getActiveLeaderboardCount(ctx context.Context, user_id string) (int
 	err := db.conn.QueryRow(ctx, `
 		SELECT COUNT(*)
 		FROM leaderboards
-		WHERE created_by=$1 AND (stop > NOW() OR stop is NULL)
+		WHERE created_by=$1
+			AND archived_at IS NULL
+			AND deleted_at IS NULL
+			AND (stop > NOW() OR stop is NULL)
 		`, user_id).Scan(&rowCount)
 	if err != nil {
 		return -1, err
@@ -483,15 +1232,15 @@ func (db DB) createTestUser(ctx context.Context, id string, name string, email s
 		return tx_err
 	}
 
-	_, tx_err = tx.Exec(ctx, `DELETE FROM "user" WHERE name=$1 OR email=$2`, name, email)
+	_, tx_err = tx.Exec(ctx, `DELETE FROM "user" WHERE name=$1 OR username=$1 OR email=$2`, name, email)
 	if tx_err != nil {
 		log.Println("Couldn't clear from user table", tx_err)
 		return tx_err
 	}
 
 	_, tx_err = tx.Exec(ctx, `
-			INSERT INTO "user"(id, name, email, "emailVerified", "createdAt", "updatedAt", "isAnonymous")
-			VALUES ($1, $2, $3, FALSE, NOW(), NOW(), $4)
+			INSERT INTO "user"(id, name, username, email, "emailVerified", "createdAt", "updatedAt", "isAnonymous")
+			VALUES ($1, $2, $2, $3, FALSE, NOW(), NOW(), $4)
 		`, id, name, email, is_anonymous)
 	if tx_err != nil {
 		log.Println(tx_err)
@@ -510,6 +1259,30 @@ func (db DB) createTestUser(ctx context.Context, id string, name string, email s
 	return commit_err
 }
 
+func (db DB) createTestSession(ctx context.Context, user_id string, token string, expires_at time.Time) error {
+	_, err := db.conn.Exec(ctx, `
+		INSERT INTO "session"(id, "expiresAt", token, "createdAt", "updatedAt", "userId")
+		VALUES ($2, $3, $2, NOW(), NOW(), $1)
+		`, user_id, token, expires_at)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (db DB) getSessionUser(ctx context.Context, token string) (User, error) {
+	var user User
+	err := db.conn.QueryRow(ctx, `
+		SELECT "user".id, "user".name
+		FROM "session"
+		JOIN "user"
+		ON "user".id="session"."userId"
+		WHERE "session".token=$1 AND "session"."expiresAt" > NOW()
+		`, token).Scan(&user.ID, &user.Username)
+
+	return user, err
+}
+
 func (db DB) getCustomer(ctx context.Context, id string) (CustomerInfo, error) {
 	row := db.conn.QueryRow(ctx, `
 		SELECT customer_id, status
@@ -559,8 +1332,567 @@ func (db DB) getLastUpdatedTime(ctx context.Context, leaderboard_id uuid.UUID) (
 	err := db.conn.QueryRow(ctx, `
 		SELECT last_updated
 		FROM leaderboards
-		WHERE id=$1
+		WHERE id=$1 AND deleted_at IS NULL
 		`, leaderboard_id).Scan(&lastUpdated)
 
 	return lastUpdated, err
 }
+
+func (db DB) PublishInvalidation(ctx context.Context, leaderboard_id uuid.UUID) error {
+	_, err := db.conn.Exec(ctx, "SELECT pg_notify($1, $2)", cache_invalidation_channel, leaderboard_id.String())
+	return err
+}
+
+const webhook_delivery_columns = `
+	webhook_deliveries.id, webhook_deliveries.event, webhook_deliveries.data,
+	CASE
+		WHEN webhook_deliveries.delivered_at IS NOT NULL THEN 'delivered'
+		WHEN webhook_deliveries.next_attempt_at IS NULL THEN 'failed'
+		ELSE 'pending'
+	END,
+	webhook_deliveries.attempts, webhook_deliveries.last_status, webhook_deliveries.last_error,
+	webhook_deliveries.next_attempt_at, webhook_deliveries.delivered_at, webhook_deliveries.created_at`
+
+func scanWebhookDelivery(row pgx.Row) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	err := row.Scan(&d.ID, &d.Event, &d.Data, &d.Status, &d.Attempts, &d.LastStatus, &d.LastError, &d.NextAttempt, &d.DeliveredAt, &d.TimeCreated)
+	return d, err
+}
+
+func (db DB) newWebhookEndpoint(ctx context.Context, leaderboard uuid.UUID, url string, secret string, events []string) (WebhookEndpoint, error) {
+	endpoint := WebhookEndpoint{URL: url, Secret: secret, Events: events}
+	err := db.conn.QueryRow(ctx, `
+		INSERT INTO webhook_endpoints(leaderboard, url, secret, events)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+		`, leaderboard, url, secret, events).Scan(&endpoint.ID, &endpoint.TimeAdded)
+	return endpoint, err
+}
+
+func (db DB) countWebhookEndpoints(ctx context.Context, leaderboard uuid.UUID) (int, error) {
+	var count int
+	err := db.conn.QueryRow(ctx, `
+		SELECT COUNT(*) FROM webhook_endpoints WHERE leaderboard=$1
+		`, leaderboard).Scan(&count)
+	return count, err
+}
+
+func (db DB) getWebhookEndpoints(ctx context.Context, leaderboard uuid.UUID) ([]WebhookEndpoint, error) {
+	rows, err := db.conn.Query(ctx, `
+		SELECT id, url, events, created_at
+		FROM webhook_endpoints
+		WHERE leaderboard=$1
+		ORDER BY created_at, id
+		`, leaderboard)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	endpoints := []WebhookEndpoint{}
+	for rows.Next() {
+		var endpoint WebhookEndpoint
+		if err := rows.Scan(&endpoint.ID, &endpoint.URL, &endpoint.Events, &endpoint.TimeAdded); err != nil {
+			return endpoints, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func (db DB) deleteWebhookEndpoint(ctx context.Context, leaderboard uuid.UUID, endpoint uuid.UUID) (int64, error) {
+	result, err := db.conn.Exec(ctx, `
+		DELETE FROM webhook_endpoints WHERE id=$1 AND leaderboard=$2
+		`, endpoint, leaderboard)
+	return result.RowsAffected(), err
+}
+
+// queueWebhook queues a delivery of the event to every endpoint of the
+// leaderboard subscribed to it.
+func (db DB) queueWebhook(ctx context.Context, leaderboard uuid.UUID, event string, data any) error {
+	_, err := db.conn.Exec(ctx, `
+		INSERT INTO webhook_deliveries(endpoint, event, data, next_attempt_at)
+		SELECT webhook_endpoints.id, $2, $3::jsonb, NOW()
+		FROM webhook_endpoints
+		JOIN leaderboards
+		ON leaderboards.id=webhook_endpoints.leaderboard
+		WHERE webhook_endpoints.leaderboard=$1
+			AND $2::text=ANY(webhook_endpoints.events)
+			AND leaderboards.deleted_at IS NULL
+		`, leaderboard, event, data)
+	return err
+}
+
+// queueClosedLeaderboardWebhooks queues leaderboard.closed for leaderboards
+// that were archived or reached their stop time, once per leaderboard.
+func (db DB) queueClosedLeaderboardWebhooks(ctx context.Context, now time.Time) error {
+	_, err := db.conn.Exec(ctx, `
+		WITH closed AS (
+			UPDATE leaderboards
+			SET close_notified_at=$1
+			WHERE close_notified_at IS NULL
+				AND deleted_at IS NULL
+				AND (archived_at IS NOT NULL OR stop + grace_period * INTERVAL '1 second' <= $1)
+			RETURNING id, (CASE WHEN archived_at IS NOT NULL THEN 'archived' ELSE 'ended' END) AS reason
+		)
+		INSERT INTO webhook_deliveries(endpoint, event, data, next_attempt_at)
+		SELECT webhook_endpoints.id, $2, json_build_object('reason', closed.reason), $1
+		FROM closed
+		JOIN webhook_endpoints
+		ON webhook_endpoints.leaderboard=closed.id
+		WHERE $2::text=ANY(webhook_endpoints.events)
+		`, now.UTC(), webhook_leaderboard_closed)
+	return err
+}
+
+// claimWebhooks takes up to limit due deliveries and counts an attempt for
+// each. They aren't due again until lease_until, so other instances skip them
+// while they're being sent. Deliveries for deleted leaderboards are never sent.
+func (db DB) claimWebhooks(ctx context.Context, now time.Time, lease_until time.Time, limit int) ([]queuedWebhook, error) {
+	rows, err := db.conn.Query(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts=webhook_deliveries.attempts + 1, next_attempt_at=$2, last_attempt_at=$1
+		FROM webhook_endpoints
+		JOIN leaderboards
+		ON leaderboards.id=webhook_endpoints.leaderboard
+		WHERE webhook_deliveries.id IN (
+				SELECT webhook_deliveries.id
+				FROM webhook_deliveries
+				JOIN webhook_endpoints
+				ON webhook_endpoints.id=webhook_deliveries.endpoint
+				JOIN leaderboards
+				ON leaderboards.id=webhook_endpoints.leaderboard
+				WHERE webhook_deliveries.next_attempt_at <= $1
+					AND leaderboards.deleted_at IS NULL
+				ORDER BY webhook_deliveries.next_attempt_at
+				LIMIT $3
+				FOR UPDATE OF webhook_deliveries SKIP LOCKED
+			)
+			AND webhook_endpoints.id=webhook_deliveries.endpoint
+			AND leaderboards.deleted_at IS NULL
+		RETURNING webhook_deliveries.id, leaderboards.seq, webhook_deliveries.event, webhook_deliveries.data,
+			webhook_deliveries.created_at, webhook_deliveries.attempts, webhook_endpoints.url, webhook_endpoints.secret
+		`, now.UTC(), lease_until.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	claimed := []queuedWebhook{}
+	for rows.Next() {
+		var w queuedWebhook
+		if err := rows.Scan(&w.ID, &w.Leaderboard, &w.Event, &w.Data, &w.TimeCreated, &w.Attempts, &w.URL, &w.Secret); err != nil {
+			return claimed, err
+		}
+		claimed = append(claimed, w)
+	}
+	return claimed, rows.Err()
+}
+
+// recordWebhookAttempt stores the outcome of sending a claimed delivery. A nil
+// next_attempt with no delivered_at marks it as failed.
+func (db DB) recordWebhookAttempt(ctx context.Context, delivery uuid.UUID, status *int, attempt_err *string, delivered_at *time.Time, next_attempt *time.Time) error {
+	_, err := db.conn.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET last_status=$2, last_error=$3, delivered_at=$4, next_attempt_at=$5
+		WHERE id=$1
+		`, delivery, status, attempt_err, delivered_at, next_attempt)
+	return err
+}
+
+func (db DB) getWebhookDeliveries(ctx context.Context, leaderboard uuid.UUID, endpoint uuid.UUID, after *Cursor, limit int) ([]WebhookDelivery, error) {
+	_, after_time, after_id := after.keyset()
+	rows, err := db.conn.Query(ctx, `
+		SELECT `+webhook_delivery_columns+`
+		FROM webhook_deliveries
+		JOIN webhook_endpoints
+		ON webhook_endpoints.id=webhook_deliveries.endpoint
+		WHERE webhook_endpoints.id=$1 AND webhook_endpoints.leaderboard=$2
+			AND ($3::timestamp IS NULL OR (webhook_deliveries.created_at, webhook_deliveries.id) < ($3::timestamp, $4::text::uuid))
+		ORDER BY
+			webhook_deliveries.created_at DESC,
+			webhook_deliveries.id DESC
+		LIMIT $5
+		`, endpoint, leaderboard, after_time, after_id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// redeliverWebhook queues a delivered or failed delivery to be sent again
+// with a fresh set of attempts.
+func (db DB) redeliverWebhook(ctx context.Context, leaderboard uuid.UUID, endpoint uuid.UUID, delivery uuid.UUID) (WebhookDelivery, error) {
+	return scanWebhookDelivery(db.conn.QueryRow(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts=0, next_attempt_at=NOW(), delivered_at=NULL
+		FROM webhook_endpoints
+		WHERE webhook_deliveries.id=$1
+			AND webhook_deliveries.endpoint=$2
+			AND webhook_endpoints.id=webhook_deliveries.endpoint
+			AND webhook_endpoints.leaderboard=$3
+			AND webhook_deliveries.next_attempt_at IS NULL
+		RETURNING `+webhook_delivery_columns+`
+		`, delivery, endpoint, leaderboard))
+}
+
+func (db DB) getWebhookDelivery(ctx context.Context, leaderboard uuid.UUID, endpoint uuid.UUID, delivery uuid.UUID) (WebhookDelivery, error) {
+	return scanWebhookDelivery(db.conn.QueryRow(ctx, `
+		SELECT `+webhook_delivery_columns+`
+		FROM webhook_deliveries
+		JOIN webhook_endpoints
+		ON webhook_endpoints.id=webhook_deliveries.endpoint
+		WHERE webhook_deliveries.id=$1 AND webhook_endpoints.id=$2 AND webhook_endpoints.leaderboard=$3
+		`, delivery, endpoint, leaderboard))
+}
+
+func (db DB) newAPIKey(ctx context.Context, leaderboard uuid.UUID, user_id string, name string, prefix string, key_hash string, scopes []string) (APIKey, error) {
+	key := APIKey{Leaderboard: leaderboard, CreatedBy: user_id, Name: name, Prefix: prefix, Scopes: scopes}
+	err := db.conn.QueryRow(ctx, `
+		INSERT INTO api_keys(leaderboard, created_by, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, (SELECT seq FROM leaderboards WHERE id=$1)
+		`, leaderboard, user_id, name, prefix, key_hash, scopes).Scan(&key.ID, &key.TimeCreated, &key.LeaderboardID)
+	return key, err
+}
+
+func (db DB) getAPIKeys(ctx context.Context, leaderboard uuid.UUID) ([]APIKey, error) {
+	rows, err := db.conn.Query(ctx, `
+		SELECT api_keys.id, api_keys.leaderboard, leaderboards.seq, api_keys.created_by, api_keys.name, api_keys.prefix, api_keys.scopes, api_keys.created_at, api_keys.last_used_at
+		FROM api_keys
+		JOIN leaderboards
+		ON leaderboards.id=api_keys.leaderboard
+		WHERE api_keys.leaderboard=$1 AND api_keys.revoked_at IS NULL
+		ORDER BY api_keys.created_at, api_keys.id
+		`, leaderboard)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		if err := rows.Scan(&key.ID, &key.Leaderboard, &key.LeaderboardID, &key.CreatedBy, &key.Name, &key.Prefix, &key.Scopes, &key.TimeCreated, &key.LastUsed); err != nil {
+			return keys, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// useAPIKey looks up an unrevoked key on a live leaderboard by its hash and
+// records that it was used.
+func (db DB) useAPIKey(ctx context.Context, key_hash string) (APIKey, error) {
+	var key APIKey
+	err := db.conn.QueryRow(ctx, `
+		UPDATE api_keys
+		SET last_used_at=NOW()
+		FROM leaderboards
+		WHERE api_keys.key_hash=$1
+			AND api_keys.revoked_at IS NULL
+			AND leaderboards.id=api_keys.leaderboard
+			AND leaderboards.deleted_at IS NULL
+		RETURNING api_keys.id, api_keys.leaderboard, leaderboards.seq, api_keys.created_by, api_keys.name, api_keys.prefix, api_keys.scopes, api_keys.created_at, api_keys.last_used_at
+		`, key_hash).Scan(&key.ID, &key.Leaderboard, &key.LeaderboardID, &key.CreatedBy, &key.Name, &key.Prefix, &key.Scopes, &key.TimeCreated, &key.LastUsed)
+	return key, err
+}
+
+func (db DB) revokeAPIKey(ctx context.Context, leaderboard uuid.UUID, key uuid.UUID) (int64, error) {
+	result, err := db.conn.Exec(ctx, `
+		UPDATE api_keys
+		SET revoked_at=NOW()
+		WHERE id=$1 AND leaderboard=$2 AND revoked_at IS NULL
+		`, key, leaderboard)
+	return result.RowsAffected(), err
+}
+
+func (db DB) getSubmissionSecret(ctx context.Context, leaderboard uuid.UUID) (*string, error) {
+	var secret *string
+	err := db.conn.QueryRow(ctx, `
+		SELECT submission_secret
+		FROM leaderboards
+		WHERE id=$1 AND deleted_at IS NULL
+		`, leaderboard).Scan(&secret)
+	return secret, err
+}
+
+// setSubmissionSecret sets or, given nil, clears the signing secret.
+func (db DB) setSubmissionSecret(ctx context.Context, leaderboard uuid.UUID, secret *string) error {
+	_, err := db.conn.Exec(ctx, `
+		UPDATE leaderboards
+		SET submission_secret=$2
+		WHERE id=$1 AND deleted_at IS NULL
+		`, leaderboard, secret)
+	return err
+}
+
+// useSubmissionNonce records the nonce, returning false if it was already used.
+func (db DB) useSubmissionNonce(ctx context.Context, leaderboard uuid.UUID, nonce string) (bool, error) {
+	result, err := db.conn.Exec(ctx, `
+		INSERT INTO submission_nonces(leaderboard, nonce)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+		`, leaderboard, nonce)
+	return result.RowsAffected() == 1, err
+}
+
+func (db DB) releaseSubmissionNonce(ctx context.Context, leaderboard uuid.UUID, nonce string) error {
+	_, err := db.conn.Exec(ctx, `
+		DELETE FROM submission_nonces WHERE leaderboard=$1 AND nonce=$2
+		`, leaderboard, nonce)
+	return err
+}
+
+func (db DB) deleteSubmissionNonces(ctx context.Context, before time.Time) error {
+	_, err := db.conn.Exec(ctx, `
+		DELETE FROM submission_nonces WHERE created_at < $1
+		`, before.UTC())
+	return err
+}
+
+// reserveIdempotencyKey claims the key unless it's held by a response saved
+// after expired_before or a request still in progress since lease_before.
+func (db DB) reserveIdempotencyKey(ctx context.Context, owner string, key string, request_hash string, now time.Time, expired_before time.Time, lease_before time.Time) (bool, error) {
+	result, err := db.conn.Exec(ctx, `
+		INSERT INTO idempotency_keys(owner, key, request_hash, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (owner, key) DO UPDATE
+		SET request_hash=EXCLUDED.request_hash, response=NULL, created_at=EXCLUDED.created_at
+		WHERE idempotency_keys.created_at < $5
+			OR (idempotency_keys.response IS NULL AND idempotency_keys.created_at < $6)
+		`, owner, key, request_hash, now.UTC(), expired_before.UTC(), lease_before.UTC())
+	return result.RowsAffected() == 1, err
+}
+
+func (db DB) getIdempotentResponse(ctx context.Context, owner string, key string) (string, []byte, error) {
+	var request_hash string
+	var response []byte
+	err := db.conn.QueryRow(ctx, `
+		SELECT request_hash, response
+		FROM idempotency_keys
+		WHERE owner=$1 AND key=$2
+		`, owner, key).Scan(&request_hash, &response)
+	return request_hash, response, err
+}
+
+func (db DB) saveIdempotentResponse(ctx context.Context, owner string, key string, response []byte) error {
+	_, err := db.conn.Exec(ctx, `
+		UPDATE idempotency_keys SET response=$3 WHERE owner=$1 AND key=$2
+		`, owner, key, response)
+	return err
+}
+
+func (db DB) releaseIdempotencyKey(ctx context.Context, owner string, key string) error {
+	_, err := db.conn.Exec(ctx, `
+		DELETE FROM idempotency_keys WHERE owner=$1 AND key=$2
+		`, owner, key)
+	return err
+}
+
+func (db DB) deleteIdempotencyKeys(ctx context.Context, before time.Time) error {
+	_, err := db.conn.Exec(ctx, `
+		DELETE FROM idempotency_keys WHERE created_at < $1
+		`, before.UTC())
+	return err
+}
+
+// resolveID looks up the UUID of a row in table from its sequence number, or
+// the other way around. Neither is changed if there's no such row.
+func (db DB) resolveID(ctx context.Context, table string, id *uuid.UUID, seq *int64) error {
+	found_id, found_seq := *id, *seq
+	err := db.conn.QueryRow(ctx, `
+		SELECT id, seq
+		FROM `+pgx.Identifier{table}.Sanitize()+`
+		WHERE id=$1 OR seq=$2
+		`, *id, *seq).Scan(&found_id, &found_seq)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	*id, *seq = found_id, found_seq
+	return nil
+}
+
+// setLeaderboardSlug makes slug the leaderboard's current slug. It returns
+// false if the slug is, or was, another leaderboard's, or the leaderboard was
+// deleted. A leaderboard may take back one of its own old slugs.
+func (db DB) setLeaderboardSlug(ctx context.Context, leaderboard uuid.UUID, slug string) (bool, error) {
+	result, err := db.conn.Exec(ctx, `
+		WITH claimed AS (
+			INSERT INTO leaderboard_slugs (slug, leaderboard)
+			SELECT lower($2), id
+			FROM leaderboards
+			WHERE id=$1 AND deleted_at IS NULL
+			ON CONFLICT (slug) DO UPDATE SET created_at=NOW()
+			WHERE leaderboard_slugs.leaderboard=excluded.leaderboard
+			RETURNING leaderboard
+		)
+		UPDATE leaderboards
+		SET slug=$2, last_updated=NOW()
+		WHERE id IN (SELECT leaderboard FROM claimed) AND deleted_at IS NULL
+		`, leaderboard, slug)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// resolveSlug looks up the leaderboard a current or old slug belongs to. id
+// and seq are left unchanged if there's no such slug.
+func (db DB) resolveSlug(ctx context.Context, slug string, id *uuid.UUID, seq *int64) error {
+	err := db.conn.QueryRow(ctx, `
+		SELECT leaderboards.id, leaderboards.seq
+		FROM leaderboard_slugs
+		JOIN leaderboards
+		ON leaderboards.id=leaderboard_slugs.leaderboard
+		WHERE leaderboard_slugs.slug=lower($1)
+		`, slug).Scan(id, seq)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+func (db DB) newInvite(ctx context.Context, leaderboard uuid.UUID, user_id string, code string, max_uses *int, expires_at *time.Time) (Invite, error) {
+	invite := Invite{CreatedBy: user_id, Code: code, MaxUses: max_uses, TimeExpires: expires_at}
+	err := db.conn.QueryRow(ctx, `
+		INSERT INTO leaderboard_invites(leaderboard, created_by, code, max_uses, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+		`, leaderboard, user_id, code, max_uses, expires_at).Scan(&invite.ID, &invite.TimeCreated)
+	return invite, err
+}
+
+func (db DB) getInvites(ctx context.Context, leaderboard uuid.UUID) ([]Invite, error) {
+	rows, err := db.conn.Query(ctx, `
+		SELECT id, code, created_by, max_uses, uses, expires_at, created_at
+		FROM leaderboard_invites
+		WHERE leaderboard=$1 AND revoked_at IS NULL
+		ORDER BY created_at, id
+		`, leaderboard)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	invites := []Invite{}
+	for rows.Next() {
+		var invite Invite
+		if err := rows.Scan(&invite.ID, &invite.Code, &invite.CreatedBy, &invite.MaxUses, &invite.Uses, &invite.TimeExpires, &invite.TimeCreated); err != nil {
+			return invites, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+func (db DB) revokeInvite(ctx context.Context, leaderboard uuid.UUID, invite uuid.UUID) (int64, error) {
+	result, err := db.conn.Exec(ctx, `
+		UPDATE leaderboard_invites
+		SET revoked_at=NOW()
+		WHERE id=$1 AND leaderboard=$2 AND revoked_at IS NULL
+		`, invite, leaderboard)
+	return result.RowsAffected(), err
+}
+
+// redeemInvite makes user_id a member of the leaderboard an unrevoked,
+// unexpired and not used up invite code is for, replacing any join request. A
+// use is only counted if they weren't a member already. It's pgx.ErrNoRows if
+// the code can't be used.
+func (db DB) redeemInvite(ctx context.Context, code string, user_id string) (LeaderboardShortID, bool, error) {
+	var leaderboard LeaderboardShortID
+	var joined bool
+	err := db.conn.QueryRow(ctx, `
+		WITH invite AS (
+			SELECT leaderboard_invites.id, leaderboard_invites.leaderboard, leaderboard_invites.created_by, leaderboards.seq
+			FROM leaderboard_invites
+			JOIN leaderboards
+			ON leaderboards.id=leaderboard_invites.leaderboard
+			WHERE leaderboard_invites.code=$1 AND leaderboard_invites.revoked_at IS NULL
+				AND (leaderboard_invites.expires_at IS NULL OR leaderboard_invites.expires_at > NOW())
+				AND (leaderboard_invites.max_uses IS NULL OR leaderboard_invites.uses < leaderboard_invites.max_uses)
+				AND leaderboards.deleted_at IS NULL
+			FOR UPDATE OF leaderboard_invites
+		), joined AS (
+			INSERT INTO leaderboard_members(leaderboard, userid, added_by)
+			SELECT leaderboard, $2, created_by
+			FROM invite
+			ON CONFLICT DO NOTHING
+			RETURNING leaderboard
+		), used AS (
+			UPDATE leaderboard_invites
+			SET uses=uses+1
+			WHERE id IN (SELECT id FROM invite) AND EXISTS(SELECT 1 FROM joined)
+		), requested AS (
+			DELETE FROM leaderboard_join_requests
+			WHERE leaderboard IN (SELECT leaderboard FROM invite) AND userid=$2
+		)
+		SELECT seq, EXISTS(SELECT 1 FROM joined)
+		FROM invite
+		`, code, user_id).Scan(&leaderboard, &joined)
+	return leaderboard, joined, err
+}
+
+// requestToJoin asks to join the leaderboard. Asking again keeps the first request.
+func (db DB) requestToJoin(ctx context.Context, leaderboard uuid.UUID, user_id string, message string) error {
+	_, err := db.conn.Exec(ctx, `
+		INSERT INTO leaderboard_join_requests(leaderboard, userid, message)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		`, leaderboard, user_id, message)
+	return err
+}
+
+func (db DB) getJoinRequests(ctx context.Context, leaderboard uuid.UUID) ([]JoinRequest, error) {
+	rows, err := db.conn.Query(ctx, `
+		SELECT leaderboard_join_requests.userid, "user".name, leaderboard_join_requests.message, leaderboard_join_requests.created_at
+		FROM leaderboard_join_requests
+		LEFT JOIN "user"
+		ON "user".id=leaderboard_join_requests.userid
+		WHERE leaderboard=$1
+		ORDER BY leaderboard_join_requests.created_at, leaderboard_join_requests.userid
+		`, leaderboard)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	requests := []JoinRequest{}
+	for rows.Next() {
+		var request JoinRequest
+		if err := rows.Scan(&request.User.ID, &request.User.Username, &request.Message, &request.TimeRequested); err != nil {
+			return requests, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// answerJoinRequest removes a join request, making its user a member if
+// approved. It returns 0 if there was no such request.
+func (db DB) answerJoinRequest(ctx context.Context, leaderboard uuid.UUID, user_id string, approved bool, author string) (int64, error) {
+	var count int64
+	err := db.conn.QueryRow(ctx, `
+		WITH request AS (
+			DELETE FROM leaderboard_join_requests
+			WHERE leaderboard=$1 AND userid=$2
+			RETURNING leaderboard, userid
+		), joined AS (
+			INSERT INTO leaderboard_members(leaderboard, userid, added_by)
+			SELECT leaderboard, userid, $4
+			FROM request
+			WHERE $3
+			ON CONFLICT DO NOTHING
+		)
+		SELECT COUNT(*)
+		FROM request
+		`, leaderboard, user_id, approved, author).Scan(&count)
+	return count, err
+}
@@ -5,7 +5,10 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/gofrs/uuid/v5"
 	"github.com/jackc/pgx/v5"
@@ -24,15 +27,152 @@ func TestNewLeaderboardDB(t *testing.T) {
 		})
 		assert.NoError(t, err)
 
-		leaderboard_id, err := db.newLeaderboard(ctx, "meowid", LeaderboardConfig{
+		leaderboard_id, _, err := db.newLeaderboard(ctx, "meowid", LeaderboardConfig{
 			Title: "My Leaderboard",
-		})
+		}, nil)
 
 		assert.NotEqual(t, uuid.Nil, leaderboard_id)
 		assert.NoError(t, err)
 	})
 }
 
+func TestArchivedLeaderboardFreesQuota(t *testing.T) {
+	WithTx(t, func(ctx context.Context, tx pgx.Tx) {
+		db := DB{
+			conn: tx,
+		}
+		err := db.createTestUser(ctx, "meowid", "meow", "meow@meow", false, CustomerInfo{
+			id:              123123,
+			subscription_id: 123123,
+		})
+		assert.NoError(t, err)
+
+		leaderboard_id, _, err := db.newLeaderboard(ctx, "meowid", LeaderboardConfig{
+			Title: "My Leaderboard",
+			Start: time.Now().Add(-time.Hour),
+		}, nil)
+		assert.NoError(t, err)
+
+		count, err := db.getActiveLeaderboardCount(ctx, "meowid")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		archived, err := db.archiveLeaderboard(ctx, leaderboard_id)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), archived)
+
+		count, err = db.getActiveLeaderboardCount(ctx, "meowid")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func TestDeletedLeaderboardDoesNotClaimSlug(t *testing.T) {
+	WithTx(t, func(ctx context.Context, tx pgx.Tx) {
+		db := DB{
+			conn: tx,
+		}
+		err := db.createTestUser(ctx, "meowid", "meow", "meow@meow", false, CustomerInfo{
+			id:              123123,
+			subscription_id: 123123,
+		})
+		assert.NoError(t, err)
+
+		deleted, _, err := db.newLeaderboard(ctx, "meowid", LeaderboardConfig{Title: "Deleted"}, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, db.deleteLeaderboard(ctx, deleted))
+		claimed, err := db.setLeaderboardSlug(ctx, deleted, "deleted-board")
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+
+		live, _, err := db.newLeaderboard(ctx, "meowid", LeaderboardConfig{Title: "Live"}, nil)
+		assert.NoError(t, err)
+		claimed, err = db.setLeaderboardSlug(ctx, live, "deleted-board")
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+	})
+}
+
+func TestReleasedSubmissionNonceCanBeReused(t *testing.T) {
+	WithTx(t, func(ctx context.Context, tx pgx.Tx) {
+		db := DB{
+			conn: tx,
+		}
+		err := db.createTestUser(ctx, "meowid", "meow", "meow@meow", false, CustomerInfo{
+			id:              123123,
+			subscription_id: 123123,
+		})
+		assert.NoError(t, err)
+		leaderboard_id, _, err := db.newLeaderboard(ctx, "meowid", LeaderboardConfig{Title: "Signed"}, nil)
+		assert.NoError(t, err)
+
+		fresh, err := db.useSubmissionNonce(ctx, leaderboard_id, "nonce-1")
+		assert.NoError(t, err)
+		assert.True(t, fresh)
+		fresh, err = db.useSubmissionNonce(ctx, leaderboard_id, "nonce-1")
+		assert.NoError(t, err)
+		assert.False(t, fresh)
+
+		assert.NoError(t, db.releaseSubmissionNonce(ctx, leaderboard_id, "nonce-1"))
+		fresh, err = db.useSubmissionNonce(ctx, leaderboard_id, "nonce-1")
+		assert.NoError(t, err)
+		assert.True(t, fresh)
+	})
+}
+
+func TestRollPeriods(t *testing.T) {
+	WithTx(t, func(ctx context.Context, tx pgx.Tx) {
+		db := DB{
+			conn: tx,
+		}
+		err := db.createTestUser(ctx, "meowid", "meow", "meow@meow", false, CustomerInfo{
+			id:              123123,
+			subscription_id: 123123,
+		})
+		assert.NoError(t, err)
+
+		first_stop := time.Now().UTC().Add(-time.Hour)
+		leaderboard_id, _, err := db.newLeaderboard(ctx, "meowid", LeaderboardConfig{
+			Title:      "Daily Leaderboard",
+			Start:      time.Now().Add(-48 * time.Hour),
+			Recurrence: "daily",
+		}, &first_stop)
+		assert.NoError(t, err)
+
+		score, _ := NewScore("10")
+		_, _, err = db.newSubmission(ctx, leaderboard_id, "meowid", score, "www.youtube.com")
+		assert.NoError(t, err)
+
+		now := time.Now()
+		rolled, err := db.rollPeriods(ctx, now)
+		assert.NoError(t, err)
+		assert.Contains(t, rolled, leaderboard_id)
+
+		period, err := db.getLeaderboardPeriod(ctx, leaderboard_id, 2)
+		if assert.NoError(t, err) && assert.NotNil(t, period.Start) && assert.NotNil(t, period.Stop) {
+			assert.WithinDuration(t, first_stop, *period.Start, time.Second)
+			assert.True(t, period.Stop.After(now))
+		}
+
+		rolled, err = db.rollPeriods(ctx, now)
+		assert.NoError(t, err)
+		assert.NotContains(t, rolled, leaderboard_id)
+
+		// The submission was made after period 1 ended.
+		past, err := db.getLeaderboard(ctx, leaderboard_id, &period.Number, nil, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(past))
+		first := 1
+		past, err = db.getLeaderboard(ctx, leaderboard_id, &first, nil, 10)
+		assert.NoError(t, err)
+		assert.Zero(t, len(past))
+
+		current, err := db.getLeaderboard(ctx, leaderboard_id, nil, nil, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(current))
+	})
+}
+
 func TestCreateTestUser(t *testing.T) {
 	WithTx(t, func(ctx context.Context, tx pgx.Tx) {
 
@@ -72,3 +212,81 @@ func TestCreateTestUser(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestPublishInvalidation(t *testing.T) {
+	WithTx(t, func(ctx context.Context, tx pgx.Tx) {
+		db := DB{conn: tx}
+		assert.NoError(t, db.PublishInvalidation(ctx, uuid.Must(uuid.NewV4())))
+	})
+}
+
+func TestMigrateUpIsIdempotent(t *testing.T) {
+	assert.NoError(t, runMigrations(t.Context(), os.Getenv("DB_URL")))
+
+	conn, err := pgx.Connect(t.Context(), os.Getenv("DB_URL"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close(t.Context())
+
+	ran, err := migrateUp(t.Context(), conn)
+	assert.NoError(t, err)
+	assert.Empty(t, ran)
+
+	statuses, err := migrationStatus(t.Context(), conn)
+	assert.NoError(t, err)
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt, "migration %d_%s not applied", s.Version, s.Name)
+	}
+}
+
+// scratchConn connects to a schema of its own, dropped when the test ends, so
+// migrating down doesn't touch the schema shared by the other tests.
+func scratchConn(t *testing.T) *pgx.Conn {
+	t.Helper()
+	config, err := pgx.ParseConfig(os.Getenv("DB_URL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	config.RuntimeParams["search_path"] = schema
+
+	conn, err := pgx.ConnectConfig(t.Context(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(t.Context(), "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		conn.Close(context.Background())
+	})
+	return conn
+}
+
+func TestMigrateDownUp(t *testing.T) {
+	conn := scratchConn(t)
+	_, err := migrateUp(t.Context(), conn)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	reverted, err := migrateDown(t.Context(), conn, 1)
+	assert.NoError(t, err)
+	if !assert.Equal(t, 1, len(reverted)) {
+		return
+	}
+
+	statuses, err := migrationStatus(t.Context(), conn)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, statuses) {
+		assert.Nil(t, statuses[len(statuses)-1].AppliedAt)
+	}
+
+	ran, err := migrateUp(t.Context(), conn)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(ran)) {
+		assert.Equal(t, reverted[0].Version, ran[0].Version)
+	}
+}
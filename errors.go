@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+)
+
+// Codes for errors mapped from database failures. Every other error's code is
+// its status text in snake case, e.g. not_found or unprocessable_entity.
+const (
+	error_code_already_exists       = "already_exists"
+	error_code_invalid_reference    = "invalid_reference"
+	error_code_constraint_violation = "constraint_violation"
+)
+
+// Codes for submissions made outside the leaderboard's start/stop window.
+const (
+	error_code_leaderboard_upcoming = "leaderboard_upcoming"
+	error_code_leaderboard_closed   = "leaderboard_closed"
+)
+
+// APIError is the body of every error: huma's problem details plus a
+// code that clients can match on, which stays the same between releases.
+type APIError struct {
+	huma.ErrorModel
+	Code string `json:"code" example:"not_found" doc:"Machine readable error code."`
+}
+
+func init() {
+	huma.NewError = newError
+}
+
+// newError replaces huma.NewError, so it's used for errors returned by every
+// handler. Database errors a handler didn't handle itself are mapped to a
+// client error where possible, and other unexpected errors are logged rather
+// than shown to the client.
+func newError(status int, msg string, errs ...error) huma.StatusError {
+	if status != http.StatusInternalServerError {
+		return codedError(status, statusErrorCode(status), msg, errs...)
+	}
+	for _, err := range errs {
+		if mapped := dbError(err); mapped != nil {
+			return mapped
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			log.Printf("%s: %v", msg, err)
+		}
+	}
+	return codedError(status, statusErrorCode(status), msg)
+}
+
+func statusErrorCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+func codedError(status int, code string, msg string, errs ...error) *APIError {
+	details := []*huma.ErrorDetail{}
+	for _, err := range errs {
+		if converted, ok := err.(huma.ErrorDetailer); ok {
+			details = append(details, converted.ErrorDetail())
+		} else if err != nil {
+			details = append(details, &huma.ErrorDetail{Message: err.Error()})
+		}
+	}
+	return &APIError{
+		ErrorModel: huma.ErrorModel{
+			Status: status,
+			Title:  http.StatusText(status),
+			Detail: msg,
+			Errors: details,
+		},
+		Code: code,
+	}
+}
+
+// isDBError reports whether err is a database error with the given code.
+func isDBError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+// dbError returns the client error for a missing row or a violated
+// constraint, or nil for any other error.
+func dbError(err error) huma.StatusError {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return codedError(http.StatusNotFound, statusErrorCode(http.StatusNotFound), "Not found.")
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	switch pgErr.Code {
+	case pgerrcode.UniqueViolation:
+		return codedError(http.StatusConflict, error_code_already_exists, "Already exists.")
+	case pgerrcode.ForeignKeyViolation:
+		return codedError(http.StatusUnprocessableEntity, error_code_invalid_reference, "Refers to something that doesn't exist.")
+	case pgerrcode.CheckViolation:
+		return codedError(http.StatusBadRequest, error_code_constraint_violation, "A value is out of range.")
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/sse"
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Channel the submissions trigger notifies on, see function_update_timestamp.
+const leaderboard_events_channel = "leaderboard_events"
+
+// Number of undelivered events a stream may fall behind by before new events
+// for it are dropped.
+const event_buffer_size = 16
+
+// How often idle streams are sent a ping, so proxies don't close them.
+const stream_ping_interval = 30 * time.Second
+
+type LeaderboardEvent struct {
+	Leaderboard uuid.UUID `json:"leaderboard"`
+	Submission  uuid.UUID `json:"submission"`
+	Event       string    `json:"event"`
+}
+
+// Broker fans leaderboard events out to the streams subscribed to each
+// leaderboard on this instance.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan LeaderboardEvent]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: map[uuid.UUID]map[chan LeaderboardEvent]struct{}{},
+	}
+}
+
+// Subscribe returns a channel of events for the leaderboard and a function
+// to call once the subscriber is done.
+func (b *Broker) Subscribe(leaderboard uuid.UUID) (<-chan LeaderboardEvent, func()) {
+	events := make(chan LeaderboardEvent, event_buffer_size)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[leaderboard] == nil {
+		b.subscribers[leaderboard] = map[chan LeaderboardEvent]struct{}{}
+	}
+	b.subscribers[leaderboard][events] = struct{}{}
+
+	return events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[leaderboard], events)
+		if len(b.subscribers[leaderboard]) == 0 {
+			delete(b.subscribers, leaderboard)
+		}
+	}
+}
+
+func (b *Broker) subscriberCount(leaderboard uuid.UUID) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers[leaderboard])
+}
+
+// Publish delivers the event to every subscriber without blocking, skipping
+// subscribers whose buffer is full.
+func (b *Broker) Publish(event LeaderboardEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for events := range b.subscribers[event.Leaderboard] {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// PublishPayload publishes a leaderboard_events notification payload.
+func (b *Broker) PublishPayload(payload string) {
+	var event LeaderboardEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("Ignoring malformed leaderboard event %q: %v", payload, err)
+		return
+	}
+	b.Publish(event)
+}
+
+// leaderboardExists responds with a 404 before a stream is opened for a
+// leaderboard that doesn't exist, since SSE handlers can't return errors.
+func (app *App) leaderboardExists(ctx huma.Context, next func(huma.Context)) {
+	raw := ctx.Param("leaderboard_id")
+	var db_err error
+	id, seq, ok := parseID(ids.leaderboards, raw)
+	switch {
+	case isSlug(raw):
+		db_err = app.st.resolveSlug(ctx.Context(), raw, &id, &seq)
+	case ok:
+		db_err = app.st.resolveID(ctx.Context(), "leaderboards", &id, &seq)
+	default:
+		// Let parameter validation report it.
+		next(ctx)
+		return
+	}
+	if db_err == nil {
+		_, db_err = app.st.getLastUpdatedTime(ctx.Context(), id)
+	}
+	if db_err == pgx.ErrNoRows {
+		huma.WriteErr(app.api, ctx, http.StatusNotFound, "Leaderboard not found.")
+		return
+	}
+	if db_err != nil {
+		huma.WriteErr(app.api, ctx, http.StatusInternalServerError, "Could not find leaderboard.", db_err)
+		return
+	}
+	next(ctx)
+}
+
+// streamLeaderboard sends the current rankings, then for every change to a
+// submission a submission event followed by the refreshed rankings.
+func (app *App) streamLeaderboard(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	StreamParams
+}, send sse.Sender) {
+	// Subscribe first so no change between the snapshot and the first event is missed.
+	events, unsubscribe := app.events.Subscribe(input.ID)
+	defer unsubscribe()
+
+	if !app.sendRankings(ctx, input.ID, input.Limit, send) {
+		return
+	}
+
+	ping := time.NewTicker(stream_ping_interval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ping.C:
+			if send.Data(StreamPing{Time: t.UTC()}) != nil {
+				return
+			}
+		case event := <-events:
+			if !app.sendSubmissionEvent(ctx, event, send) {
+				return
+			}
+			// Send the rankings once a burst of changes has been relayed.
+			if len(events) == 0 && !app.sendRankings(ctx, input.ID, input.Limit, send) {
+				return
+			}
+		}
+	}
+}
+
+func (app *App) sendRankings(ctx context.Context, leaderboard uuid.UUID, limit int, send sse.Sender) bool {
+	scores, db_err := app.st.getLeaderboard(ctx, leaderboard, nil, nil, limit)
+	if db_err != nil {
+		log.Printf("Could not get rankings for stream of %s: %v", leaderboard, db_err)
+		return false
+	}
+	return send.Data(LeaderboardResponseBody{Scores: scores}) == nil
+}
+
+func (app *App) sendSubmissionEvent(ctx context.Context, event LeaderboardEvent, send sse.Sender) bool {
+	msg := SubmissionEvent{Type: event.Event}
+	around, db_err := app.st.getSubmissionsAround(ctx, event.Leaderboard, event.Submission, 0)
+	if db_err != nil && db_err != pgx.ErrNoRows {
+		log.Printf("Could not get ranking for stream of %s: %v", event.Leaderboard, db_err)
+		return false
+	}
+	if len(around) > 0 {
+		msg.Ranking = &around[0]
+		msg.SubmissionID = around[0].ID
+	} else {
+		id, seq := event.Submission, int64(0)
+		if db_err := app.st.resolveID(ctx, "submissions", &id, &seq); db_err != nil {
+			log.Printf("Could not get ID of submission for stream of %s: %v", event.Leaderboard, db_err)
+			return false
+		}
+		msg.SubmissionID = SubmissionShortID(seq)
+	}
+	return send.Data(msg) == nil
+}
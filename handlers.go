@@ -2,22 +2,54 @@ package main
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	pgconn "github.com/jackc/pgx/v5/pgconn"
 )
 
 func (app *App) postNewLeaderboard(ctx context.Context, input *struct {
+	IdempotencyParams
 	NewLeaderboardBody
-	UserIDHeader
 }) (*NewLeaderboardResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return idempotent(ctx, app, input.IdempotencyParams, "POST /leaderboard", input.Body, func() (*NewLeaderboardResponse, error) {
+		return app.createLeaderboard(ctx, user, input.Body)
+	})
+}
+
+func (app *App) createLeaderboard(ctx context.Context, user *User, body LeaderboardConfig) (*NewLeaderboardResponse, error) {
+	// The database allows an empty window for leaderboards archived before
+	// they start.
+	if body.Stop != nil && !body.Stop.After(body.Start) {
+		return nil, huma.Error400BadRequest("If provided, end date must be after start date.")
+	}
+	if _, err := time.LoadLocation(body.Timezone); err != nil {
+		return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("unknown timezone %q", body.Timezone))
+	}
+	var first_stop *time.Time
+	if len(body.Recurrence) > 0 {
+		recurrence, err := parseRecurrence(body.Recurrence, body.Timezone)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		after := time.Now()
+		if body.Start.After(after) {
+			after = body.Start
+		}
+		stop := recurrence.Next(after).UTC()
+		first_stop = &stop
+	}
 
-	count, err := app.st.getActiveLeaderboardCount(ctx, input.UserID)
+	count, err := app.st.getActiveLeaderboardCount(ctx, user.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -27,19 +59,18 @@ func (app *App) postNewLeaderboard(ctx context.Context, input *struct {
 			return nil, api_err
 		}
 		if count > 50 {
-			return nil, errors.New("Reached new leaderboard limit -- upgrade your account or close an existing leaderboard.")
+			return nil, huma.Error403Forbidden("Reached new leaderboard limit -- upgrade your account or close an existing leaderboard.")
 		}
 	} else {
 		if count > 5 {
-			return nil, errors.New("Reached new leaderboard limit -- upgrade your account or close an existing leaderboard.")
+			return nil, huma.Error403Forbidden("Reached new leaderboard limit -- upgrade your account or close an existing leaderboard.")
 		}
 	}
 
-	id, db_err := app.st.newLeaderboard(ctx, input.UserID, input.Body)
+	leaderboard_id, id, db_err := app.st.newLeaderboard(ctx, user.ID, body, first_stop)
 
 	if db_err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(db_err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+		if isDBError(db_err, pgerrcode.CheckViolation) {
 			return nil, huma.Error400BadRequest("If provided, end date must be after start date.")
 		}
 		return nil, db_err
@@ -47,20 +78,62 @@ func (app *App) postNewLeaderboard(ctx context.Context, input *struct {
 
 	resp := &NewLeaderboardResponse{}
 	resp.Body.Id = id
+	resp.Body.UUID = leaderboard_id
 	return resp, db_err
 }
 
-func (app *App) postNewScore(ctx context.Context, input *struct {
+type NewScoreInput struct {
 	LeaderboardIDParam
-	UserIDHeader
+	SubmissionSignatureParams
+	IdempotencyParams
 	NewSubmissionRequest
-}) (*SubmissionResponse, error) {
-	s_id, db_err := app.st.newSubmission(ctx, input.ID, input.UserID, input.Body.Score, input.Body.Link)
+}
+
+func (app *App) postNewScore(ctx context.Context, input *NewScoreInput) (*SubmissionResponse, error) {
+	route := "POST /leaderboard/" + input.ID.String() + "/submission"
+	return idempotent(ctx, app, input.IdempotencyParams, route, input.Body, func() (*SubmissionResponse, error) {
+		return app.submitScore(ctx, input)
+	})
+}
+
+func (app *App) submitScore(ctx context.Context, input *NewScoreInput) (*SubmissionResponse, error) {
+	player, err := app.submittingPlayer(ctx, input.ID, input.Body.UserID, input.Body.Username)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireEntrant(ctx, input.ID, player); err != nil {
+		return nil, err
+	}
+	rules, err := app.scoreRules(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpen(rules); err != nil {
+		return nil, err
+	}
+	if err := checkScore(rules, input.Body.Score); err != nil {
+		return nil, err
+	}
+	used_nonce, err := app.checkSubmissionSignature(ctx, input.LeaderboardIDParam, player, input.Body.Score, input.SubmissionSignatureParams)
+	if err != nil {
+		return nil, err
+	}
+
+	_, s_id, db_err := app.st.newSubmission(ctx, input.ID, player, input.Body.Score, input.Body.Link)
 	if db_err != nil {
+		if used_nonce {
+			app.releaseSubmissionNonce(ctx, input.ID, input.Nonce)
+		}
 		return nil, db_err
 	}
 
 	app.cache.Remove(input.ID)
+	app.queueWebhook(ctx, input.ID, webhook_submission_created, SubmissionWebhookData{
+		SubmissionID: s_id,
+		UserID:       player,
+		Score:        &input.Body.Score,
+		Link:         input.Body.Link,
+	})
 
 	return &SubmissionResponse{
 		SubmissionResponseBody{
@@ -69,41 +142,160 @@ func (app *App) postNewScore(ctx context.Context, input *struct {
 	}, nil
 }
 
+// submittingPlayer returns the ID of the player a new submission is for: the
+// player named by user_id or username when an API key is used, or else the
+// signed in user.
+func (app *App) submittingPlayer(ctx context.Context, leaderboard uuid.UUID, user_id string, username string) (string, error) {
+	named := len(user_id) > 0 || len(username) > 0
+	key, err := requireAPIKey(ctx, leaderboard, scope_submit)
+	if err != nil {
+		return "", err
+	}
+	var user *User
+	if key == nil {
+		if user, err = requireUser(ctx); err != nil {
+			return "", err
+		}
+		if !named {
+			return user.ID, nil
+		}
+	} else if !named {
+		return "", huma.Error422UnprocessableEntity("user_id or username is required when submitting with an API key.")
+	}
+
+	player, db_err := app.st.findUser(ctx, user_id, username)
+	if db_err == pgx.ErrNoRows {
+		return "", huma.Error404NotFound("User not found.")
+	}
+	if db_err != nil {
+		return "", db_err
+	}
+	if user != nil && player.ID != user.ID {
+		return "", huma.Error403Forbidden("Only API keys can submit scores for other players.")
+	}
+	return player.ID, nil
+}
+
 func (app *App) getLeaderboard(ctx context.Context, input *struct {
-	LastModified string `header:"If-Modified-Since"`
+	ConditionalParams
 	LeaderboardIDParam
+	RankingPageParams
 }) (*LeaderboardResponse, error) {
+	after, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	// Only the default first page is cached.
+	cacheable := after == nil && input.Limit == 100
+
 	last_updated, err := app.st.getLastUpdatedTime(ctx, input.ID)
+	if err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Leaderboard not found.")
+	}
 	if err != nil {
 		return nil, err
 	}
-	if len(input.LastModified) != 0 {
-		headerIfModifiedSince, header_parse_err := time.Parse(http.TimeFormat, input.LastModified)
-		if header_parse_err != nil {
-			return nil, header_parse_err
+	// Without an ETag to compare the rankings needn't be read at all.
+	if len(input.IfNoneMatch) == 0 && input.notModified("", last_updated) {
+		return &LeaderboardResponse{
+			Status:       http.StatusNotModified,
+			LastModified: httpTime(last_updated),
+		}, nil
+	}
+
+	var resp *LeaderboardResponse
+	if cacheable {
+		resp, _ = app.cache.Get(input.ID)
+	}
+	if resp == nil {
+		scores, db_err := app.st.getLeaderboard(ctx, input.ID, nil, after, input.Limit+1)
+		if db_err != nil {
+			return nil, db_err
+		}
+		scores, next := page(scores, input.Limit, rankingCursor)
+
+		resp = &LeaderboardResponse{Status: 200}
+		resp.Link = nextLink(input.path(), input.Limit, next)
+		resp.Body = &LeaderboardResponseBody{
+			Scores: scores,
 		}
-		if headerIfModifiedSince.Compare(last_updated) > 0 {
-			return &LeaderboardResponse{
-				Status:       http.StatusNotModified,
-				LastModified: last_updated,
-			}, nil
+		resp.ETag = strongETag(resp.Link, resp.Body)
+		if cacheable {
+			app.cache.Add(input.ID, resp)
 		}
 	}
 
-	if cached_resp, ok := app.cache.Get(input.ID); ok {
-		return cached_resp, nil
+	// Copy, since a cached response is shared between requests.
+	out := *resp
+	out.LastModified = httpTime(last_updated)
+	if input.notModified(out.ETag, last_updated) {
+		out.Status = http.StatusNotModified
 	}
+	return &out, nil
+}
+
+func rankingCursor(r Ranking) Cursor {
+	return Cursor{Score: &r.Score, Time: r.TimeSubmitted, ID: r.UUID.String()}
+}
 
-	scores, db_err := app.st.getLeaderboard(ctx, input.ID)
+func (app *App) getLeaderboardPeriod(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	PeriodParam
+	RankingPageParams
+}) (*LeaderboardPeriodResponse, error) {
+	after, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	period, db_err := app.st.getLeaderboardPeriod(ctx, input.ID, input.Period)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Period not found.")
+	}
 	if db_err != nil {
 		return nil, db_err
 	}
 
-	resp := &LeaderboardResponse{Status: 200}
-	resp.Body = &LeaderboardResponseBody{
-		Scores: scores,
+	scores, db_err := app.st.getLeaderboard(ctx, input.ID, &input.Period, after, input.Limit+1)
+	if db_err != nil {
+		return nil, db_err
 	}
-	app.cache.Add(input.ID, resp)
+	scores, next := page(scores, input.Limit, rankingCursor)
+
+	resp := &LeaderboardPeriodResponse{}
+	resp.Link = nextLink(fmt.Sprintf("%s/period/%d", input.path(), input.Period), input.Limit, next)
+	resp.Body.Period = period
+	resp.Body.Scores = scores
+	return resp, nil
+}
+
+func (app *App) getUserRank(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	UserIDParam
+}) (*UserRankResponse, error) {
+	rank, db_err := app.st.getUserRank(ctx, input.ID, input.UserID)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("User has no ranked submission on this leaderboard.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+	return &UserRankResponse{Body: rank}, nil
+}
+
+func (app *App) getSubmissionsAround(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	SubmissionIDParam
+	AroundParams
+}) (*SubmissionsAroundResponse, error) {
+	scores, db_err := app.st.getSubmissionsAround(ctx, input.ID, input.SubmissionID, input.N)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Submission is not ranked on this leaderboard.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+	resp := &SubmissionsAroundResponse{}
+	resp.Body.Scores = scores
 	return resp, nil
 }
 
@@ -112,28 +304,112 @@ func (app *App) getSubmission(ctx context.Context, input *struct {
 	SubmissionIDParam
 }) (*SubmissionInfoResponse, error) {
 	submission_info, db_err := app.st.getSubmissionInfo(ctx, input.ID, input.SubmissionID)
-
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Submission not found.")
+	}
 	if db_err != nil {
 		return nil, db_err
 	}
 
-	submission_info.LeaderboardID = input.ID
 	resp := &SubmissionInfoResponse{
 		submission_info,
 	}
 	return resp, nil
 }
 
+// requireSubmissionOwner returns a 404 if the submission doesn't exist or was
+// withdrawn, and a 403 if it belongs to someone other than user.
+func (app *App) requireSubmissionOwner(ctx context.Context, leaderboard uuid.UUID, submission uuid.UUID, user *User) error {
+	owner, db_err := app.st.getSubmissionOwner(ctx, leaderboard, submission)
+	if db_err == pgx.ErrNoRows {
+		return huma.Error404NotFound("Submission not found.")
+	}
+	if db_err != nil {
+		return db_err
+	}
+	if owner != user.ID {
+		return huma.Error403Forbidden("Only the submitter can change this submission.")
+	}
+	return nil
+}
+
+func (app *App) scoreRules(ctx context.Context, leaderboard uuid.UUID) (ScoreRules, error) {
+	rules, db_err := app.st.getScoreRules(ctx, leaderboard)
+	if db_err == pgx.ErrNoRows {
+		return rules, huma.Error404NotFound("Leaderboard not found.")
+	}
+	return rules, db_err
+}
+
+// requireOpen returns a 403 if the leaderboard hasn't started yet, or has
+// stopped and its grace period is over, so the submission wouldn't be ranked.
+func requireOpen(rules ScoreRules) error {
+	switch rules.stateAt(time.Now()) {
+	case "upcoming":
+		return codedError(http.StatusForbidden, error_code_leaderboard_upcoming, fmt.Sprintf("Leaderboard opens for submissions at %s.", rules.Start.Format(time.RFC3339)))
+	case "closed":
+		return codedError(http.StatusForbidden, error_code_leaderboard_closed, "Leaderboard is closed to new submissions.")
+	}
+	return nil
+}
+
+// checkScore rejects scores the leaderboard can't hold: times on leaderboards
+// that aren't timed, negative times, or more decimal places than allowed.
+func checkScore(rules ScoreRules, score Score) error {
+	if score.IsTime && !rules.IsTime {
+		return huma.Error422UnprocessableEntity("Time scores are only accepted on time leaderboards.")
+	}
+	if rules.IsTime && score.Int.Sign() < 0 {
+		return huma.Error422UnprocessableEntity("Time scores can't be negative.")
+	}
+	if score.Decimals() > rules.Precision {
+		return huma.Error422UnprocessableEntity(fmt.Sprintf("Score has more than %d decimal places.", rules.Precision))
+	}
+	return nil
+}
+
 func (app *App) updateSubmission(ctx context.Context, input *struct {
 	LeaderboardIDParam
 	SubmissionIDParam
-	NewSubmissionRequest
+	SubmissionSignatureParams
+	UpdateSubmissionRequest
 }) (*SubmissionResponse, error) {
-	new_score := input.Body.Score
-	new_link := input.Body.Link
-
-	_, db_err := app.st.updateSubmissionScore(ctx, input.ID, input.SubmissionID, new_score, new_link)
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if input.Body.Score == nil && input.Body.Link == nil {
+		return nil, huma.Error400BadRequest("Provide a new score or link.")
+	}
+	if err := app.requireSubmissionOwner(ctx, input.ID, input.SubmissionID, user); err != nil {
+		return nil, err
+	}
+	// Edits keep the submission's time, so they'd change a closed ranking.
+	rules, err := app.scoreRules(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpen(rules); err != nil {
+		return nil, err
+	}
+	var used_nonce bool
+	if input.Body.Score != nil {
+		if err := checkScore(rules, *input.Body.Score); err != nil {
+			return nil, err
+		}
+		// Otherwise a signed score could be edited into any other.
+		if used_nonce, err = app.checkSubmissionSignature(ctx, input.LeaderboardIDParam, user.ID, *input.Body.Score, input.SubmissionSignatureParams); err != nil {
+			return nil, err
+		}
+	}
 
+	_, db_err := app.st.updateSubmissionScore(ctx, input.ID, input.SubmissionID, user.ID, input.Body.Score, input.Body.Link, input.Body.Comment)
+	if db_err != nil && used_nonce {
+		app.releaseSubmissionNonce(ctx, input.ID, input.Nonce)
+	}
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Submission not found.")
+	}
 	if db_err != nil {
 		return nil, db_err
 	}
@@ -141,45 +417,105 @@ func (app *App) updateSubmission(ctx context.Context, input *struct {
 	app.cache.Remove(input.ID)
 	resp := &SubmissionResponse{
 		SubmissionResponseBody{
-			input.SubmissionID,
+			input.SubmissionShortID,
+		},
+	}
+	return resp, nil
+}
+
+func (app *App) withdrawSubmission(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	SubmissionIDParam
+}) (*MessageResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireSubmissionOwner(ctx, input.ID, input.SubmissionID, user); err != nil {
+		return nil, err
+	}
+
+	_, db_err := app.st.withdrawSubmission(ctx, input.ID, input.SubmissionID, user.ID)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Submission not found.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+
+	app.cache.Remove(input.ID)
+	resp := &MessageResponse{
+		Body: MessageResponseBody{
+			Message: "Submission withdrawn.",
 		},
 	}
 	return resp, nil
 }
 
 func (app *App) GetSubmissionHistory(ctx context.Context, input *struct {
+	ConditionalParams
 	LeaderboardIDParam
 	SubmissionIDParam
+	PageParams
 }) (*HistoryResponse, error) {
-	history, db_err := app.st.getSubmissionHistory(ctx, input.SubmissionID)
-
+	after, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	history, db_err := app.st.getSubmissionHistory(ctx, input.ID, input.SubmissionID, after, input.Limit+1)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Submission not found.")
+	}
 	if db_err != nil {
 		return nil, db_err
 	}
+	history, next := page(history, input.Limit, func(e HistoryEntry) Cursor {
+		return Cursor{Time: e.TimeSubmitted, ID: e.ID.String()}
+	})
 
 	resp := &HistoryResponse{
+		Status: http.StatusOK,
+		Link:   nextLink(fmt.Sprintf("%s/submission/%s/history", input.path(), input.SubmissionShortID), input.Limit, next),
 		Body: HistoryResponseBody{
 			History: history,
 		},
 	}
+	resp.ETag = strongETag(resp.Link, resp.Body)
+	if input.notModified(resp.ETag, time.Time{}) {
+		resp.Status = http.StatusNotModified
+	}
 	return resp, nil
 }
 
 func (app *App) AddSubmissionComment(ctx context.Context, input *struct {
 	LeaderboardIDParam
 	SubmissionIDParam
-	UserIDHeader
 	CommentSubmissionBody
 }) (*SubmissionResponse, error) {
-	db_err := app.st.addSubmissionComment(ctx, input.ID, input.SubmissionID, input.UserID, input.Body.Comment)
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
 
+	count, db_err := app.st.addSubmissionComment(ctx, input.ID, input.SubmissionID, user.ID, input.Body.Comment)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Submission not found.")
+	}
 	if db_err != nil {
 		return nil, db_err
 	}
+	if count == 0 {
+		return nil, huma.Error403Forbidden("Only verifiers can comment on submissions to this leaderboard.")
+	}
+	app.queueWebhook(ctx, input.ID, webhook_submission_commented, SubmissionWebhookData{
+		SubmissionID: input.SubmissionShortID,
+		UserID:       user.ID,
+		Comment:      input.Body.Comment,
+	})
 
 	resp := &SubmissionResponse{
 		SubmissionResponseBody{
-			ID: input.SubmissionID,
+			ID: input.SubmissionShortID,
 		},
 	}
 	return resp, nil
@@ -188,70 +524,356 @@ func (app *App) AddSubmissionComment(ctx context.Context, input *struct {
 func (app *App) VerifyScore(ctx context.Context, input *struct {
 	LeaderboardIDParam
 	SubmissionIDParam
-	UserIDHeader
 	VerifyScoreBody
 }) (*SubmissionResponse, error) {
-	count, db_err := app.st.verifyScore(ctx, input.ID, input.SubmissionID, input.UserID, input.Body.IsValid, input.Body.Comment)
+	// API keys verify as the user who created them.
+	key, err := requireAPIKey(ctx, input.ID, scope_verify)
+	if err != nil {
+		return nil, err
+	}
+	var verifier string
+	if key != nil {
+		verifier = key.CreatedBy
+	} else {
+		user, err := requireUser(ctx)
+		if err != nil {
+			return nil, err
+		}
+		verifier = user.ID
+	}
 
-	if count == 0 || db_err == pgx.ErrNoRows {
-		return nil, huma.Error401Unauthorized("Not authorized to verify scores for this leaderboard.")
+	count, db_err := app.st.verifyScore(ctx, input.ID, input.SubmissionID, verifier, input.Body.IsValid, input.Body.Comment)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Submission not found.")
 	}
 	if db_err != nil {
 		return nil, db_err
 	}
+	if count == 0 {
+		return nil, huma.Error401Unauthorized("Not authorized to verify scores for this leaderboard.")
+	}
 
 	app.cache.Remove(input.ID)
+	app.queueWebhook(ctx, input.ID, webhook_submission_verified, SubmissionWebhookData{
+		SubmissionID: input.SubmissionShortID,
+		UserID:       verifier,
+		IsValid:      &input.Body.IsValid,
+		Comment:      input.Body.Comment,
+	})
 	resp := &SubmissionResponse{
 		SubmissionResponseBody{
-			ID: input.SubmissionID,
+			ID: input.SubmissionShortID,
 		},
 	}
 	return resp, nil
 }
 
 func (app *App) getLeaderboardVerifiers(ctx context.Context, input *struct {
+	ConditionalParams
 	LeaderboardIDParam
+	PageParams
 }) (*LeaderboardVerifiersResponse, error) {
+	after, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
 
-	owners, db_err := app.st.getVerifiers(ctx, input.ID)
+	owners, db_err := app.st.getVerifiers(ctx, input.ID, after, input.Limit+1)
 
 	if db_err != nil {
 		return nil, db_err
 	}
+	owners, next := page(owners, input.Limit, func(u User) Cursor {
+		c := Cursor{ID: u.ID}
+		if u.TimeAdded != nil {
+			c.Time = *u.TimeAdded
+		}
+		return c
+	})
 
 	resp := &LeaderboardVerifiersResponse{
+		Status: http.StatusOK,
+		Link:   nextLink(input.path()+"/verifiers", input.Limit, next),
 		Body: LeaderboardVerifiersResponseBody{
 			owners,
 		},
 	}
+	resp.ETag = strongETag(resp.Link, resp.Body)
+	if input.notModified(resp.ETag, time.Time{}) {
+		resp.Status = http.StatusNotModified
+	}
+	return resp, nil
+}
+
+func (app *App) addLeaderboardVerifier(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	VerifierBody
+}) (*LeaderboardVerifiersResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+
+	if len(input.Body.UserID) == 0 && len(input.Body.Username) == 0 {
+		return nil, huma.Error400BadRequest("Provide a user_id or username.")
+	}
+	verifier, db_err := app.st.findUser(ctx, input.Body.UserID, input.Body.Username)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("User not found.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+
+	db_err = app.st.addVerifier(ctx, input.ID, verifier.ID, user.ID)
+	if db_err != nil {
+		if isDBError(db_err, pgerrcode.UniqueViolation) {
+			return nil, huma.Error409Conflict("User is already a verifier.")
+		}
+		return nil, db_err
+	}
+
+	return app.getLeaderboardVerifiers(ctx, &struct {
+		ConditionalParams
+		LeaderboardIDParam
+		PageParams
+	}{LeaderboardIDParam: input.LeaderboardIDParam, PageParams: PageParams{Limit: 25}})
+}
+
+func (app *App) removeLeaderboardVerifier(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	VerifierParam
+}) (*LeaderboardVerifiersResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+
+	verifier, db_err := app.st.findUser(ctx, input.Verifier, "")
+	if db_err == pgx.ErrNoRows {
+		verifier, db_err = app.st.findUser(ctx, "", input.Verifier)
+	}
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("User not found.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+	if verifier.ID == user.ID {
+		return nil, huma.Error400BadRequest("The leaderboard creator can't be removed as a verifier.")
+	}
+
+	count, db_err := app.st.removeVerifier(ctx, input.ID, verifier.ID, user.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if count == 0 {
+		return nil, huma.Error404NotFound("User is not a verifier.")
+	}
+
+	return app.getLeaderboardVerifiers(ctx, &struct {
+		ConditionalParams
+		LeaderboardIDParam
+		PageParams
+	}{LeaderboardIDParam: input.LeaderboardIDParam, PageParams: PageParams{Limit: 25}})
+}
+
+func (app *App) getLeaderboardVerifierHistory(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	PageParams
+}) (*VerifierHistoryResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	verifier, db_err := app.st.isVerifier(ctx, input.ID, user.ID)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Leaderboard not found.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+	if !verifier {
+		return nil, huma.Error403Forbidden("Only the leaderboard creator and verifiers can see verifier history.")
+	}
+
+	after, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	history, db_err := app.st.getVerifierHistory(ctx, input.ID, after, input.Limit+1)
+
+	if db_err != nil {
+		return nil, db_err
+	}
+	history, next := page(history, input.Limit, func(e VerifierHistoryEntry) Cursor {
+		return Cursor{Time: e.TimeChanged, ID: e.ID.String()}
+	})
+
+	resp := &VerifierHistoryResponse{
+		Link: nextLink(input.path()+"/verifiers/history", input.Limit, next),
+		Body: VerifierHistoryResponseBody{
+			History: history,
+		},
+	}
 	return resp, nil
 }
 
 func (app *App) getLeaderboardInfo(ctx context.Context, input *struct {
+	ConditionalParams
 	LeaderboardIDParam
 }) (*LeaderboardInfoResponse, error) {
 
 	info, db_err := app.st.getLeaderboardInfo(ctx, input.ID)
-
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Leaderboard not found.")
+	}
 	if db_err != nil {
 		return nil, db_err
 	}
 
-	resp := &LeaderboardInfoResponse{}
+	resp := &LeaderboardInfoResponse{Status: http.StatusOK}
 	resp.Body = info
-	resp.Body.ID = input.ID
+	resp.ETag = strongETag(resp.Body)
+	if input.notModified(resp.ETag, time.Time{}) {
+		resp.Status = http.StatusNotModified
+	}
+	return resp, nil
+}
+
+// requireLeaderboardOwner returns a 404 if the leaderboard doesn't exist or was
+// deleted, and a 403 if user didn't create it.
+func (app *App) requireLeaderboardOwner(ctx context.Context, leaderboard uuid.UUID, user *User) error {
+	owner, db_err := app.st.getLeaderboardOwner(ctx, leaderboard)
+	if db_err == pgx.ErrNoRows {
+		return huma.Error404NotFound("Leaderboard not found.")
+	}
+	if db_err != nil {
+		return db_err
+	}
+	if owner != user.ID {
+		return huma.Error403Forbidden("Only the leaderboard creator can change this leaderboard.")
+	}
+	return nil
+}
+
+func (app *App) updateLeaderboard(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	UpdateLeaderboardBody
+}) (*LeaderboardInfoResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	// Periods already scheduled were cut under the old ones.
+	if input.Body.Recurrence != nil || input.Body.Timezone != nil {
+		return nil, huma.Error422UnprocessableEntity("The reset schedule and timezone can't be changed after creation.")
+	}
+	if input.Body.Stop.Set {
+		rules, err := app.scoreRules(ctx, input.ID)
+		if err != nil {
+			return nil, err
+		}
+		if rules.TimeArchived != nil {
+			return nil, huma.Error409Conflict("Archived leaderboards can't be reopened.")
+		}
+		if input.Body.Stop.Time != nil && !input.Body.Stop.Time.After(rules.Start) {
+			return nil, huma.Error400BadRequest("If provided, end date must be after start date.")
+		}
+	}
+
+	db_err := app.st.updateLeaderboard(ctx, input.ID, input.Body)
+	if db_err != nil {
+		if isDBError(db_err, pgerrcode.CheckViolation) {
+			return nil, huma.Error400BadRequest("If provided, end date must be after start date.")
+		}
+		return nil, db_err
+	}
+	app.cache.Remove(input.ID)
+
+	return app.getLeaderboardInfo(ctx, &struct {
+		ConditionalParams
+		LeaderboardIDParam
+	}{LeaderboardIDParam: input.LeaderboardIDParam})
+}
+
+func (app *App) archiveLeaderboard(ctx context.Context, input *struct {
+	LeaderboardIDParam
+}) (*LeaderboardInfoResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+
+	count, db_err := app.st.archiveLeaderboard(ctx, input.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if count == 0 {
+		return nil, huma.Error409Conflict("Leaderboard is already archived.")
+	}
+	app.cache.Remove(input.ID)
+
+	return app.getLeaderboardInfo(ctx, &struct {
+		ConditionalParams
+		LeaderboardIDParam
+	}{LeaderboardIDParam: input.LeaderboardIDParam})
+}
+
+func (app *App) deleteLeaderboard(ctx context.Context, input *struct {
+	LeaderboardIDParam
+}) (*MessageResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+
+	if db_err := app.st.deleteLeaderboard(ctx, input.ID); db_err != nil {
+		return nil, db_err
+	}
+	app.cache.Remove(input.ID)
+
+	resp := &MessageResponse{
+		Body: MessageResponseBody{
+			Message: "Leaderboard deleted.",
+		},
+	}
 	return resp, nil
 }
 
 func (app *App) getAccountLeaderboards(ctx context.Context, input *struct {
 	UserIDParam
+	PageParams
 }) (*AccountLeaderboardsResponse, error) {
-	leaderboards, db_err := app.st.getAccountLeaderboards(ctx, input.UserID)
+	after, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	leaderboards, db_err := app.st.getAccountLeaderboards(ctx, input.UserID, isViewer(ctx, input.UserID), after, input.Limit+1)
 	if db_err != nil {
 		return nil, db_err
 	}
+	leaderboards, next := page(leaderboards, input.Limit, func(l LeaderboardInfo) Cursor {
+		return Cursor{Time: l.TimeCreated, ID: l.UUID.String()}
+	})
 
 	resp := &AccountLeaderboardsResponse{
+		Link: nextLink(fmt.Sprintf("/account/%s/leaderboards", url.PathEscape(input.UserID)), input.Limit, next),
 		Body: AccountLeaderboardsResponseBody{
 			leaderboards,
 		},
@@ -261,13 +883,22 @@ func (app *App) getAccountLeaderboards(ctx context.Context, input *struct {
 
 func (app *App) getAccountSubmissions(ctx context.Context, input *struct {
 	UserIDParam
+	PageParams
 }) (*AccountSubmissionsResponse, error) {
-	submissions, db_err := app.st.getAccountSubmissions(ctx, input.UserID)
+	after, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	submissions, db_err := app.st.getAccountSubmissions(ctx, input.UserID, isViewer(ctx, input.UserID), after, input.Limit+1)
 	if db_err != nil {
 		return nil, db_err
 	}
+	submissions, next := page(submissions, input.Limit, func(s DetailedSubmission) Cursor {
+		return Cursor{Time: s.TimeCreated, ID: s.UUID.String()}
+	})
 
 	resp := &AccountSubmissionsResponse{
+		Link: nextLink(fmt.Sprintf("/account/%s/submissions", url.PathEscape(input.UserID)), input.Limit, next),
 		Body: AccountSubmissionsResponseBody{
 			submissions,
 		},
@@ -276,10 +907,14 @@ func (app *App) getAccountSubmissions(ctx context.Context, input *struct {
 }
 
 func (app *App) linkAnonymousAccount(ctx context.Context, input *struct {
-	UserIDHeader
 	LinkAnonymousBody
 }) (*MessageResponse, error) {
-	db_err := app.st.linkAccounts(ctx, input.Body.AnonID, input.UserID)
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db_err := app.st.linkAccounts(ctx, input.Body.AnonID, user.ID)
 	if db_err != nil {
 		return nil, db_err
 	}
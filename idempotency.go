@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Default for how long responses to requests with an Idempotency-Key are
+// replayed, see Options.IdempotencyWindow.
+const default_idempotency_window = 24 * time.Hour
+
+// How long a request holds its Idempotency-Key before a response is saved.
+// Retries after that take over the key, so one that crashed doesn't hold it
+// for the whole window.
+const idempotency_lease = time.Minute
+
+type IdempotencyParams struct {
+	IdempotencyKey string `header:"Idempotency-Key" maxLength:"255" doc:"Unique key for the request. Retrying with the same key returns the original response instead of creating a duplicate."`
+}
+
+// idempotencyOwner returns who keys are scoped to: the user, or the API key
+// used for the request.
+func idempotencyOwner(ctx context.Context) string {
+	if user, ok := ctx.Value(USER_CONTEXT_KEY).(*User); ok && user != nil {
+		return user.ID
+	}
+	if key, ok := ctx.Value(API_KEY_CONTEXT_KEY).(*APIKey); ok && key != nil {
+		return "api_key:" + key.ID.String()
+	}
+	return ""
+}
+
+// idempotent runs f once per Idempotency-Key, replaying its response for
+// retries with the same key and request within the window. Failed requests
+// aren't stored, so they can be retried with the same key.
+func idempotent[T any](ctx context.Context, app *App, params IdempotencyParams, route string, request any, f func() (*T, error)) (*T, error) {
+	owner := idempotencyOwner(ctx)
+	if len(params.IdempotencyKey) == 0 || len(owner) == 0 {
+		return f()
+	}
+	b, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(append([]byte(route+"\n"), b...))
+	request_hash := hex.EncodeToString(sum[:])
+
+	now := time.Now()
+	reserved, db_err := app.st.reserveIdempotencyKey(ctx, owner, params.IdempotencyKey, request_hash, now, now.Add(-app.idempotencyWindow), now.Add(-idempotency_lease))
+	if db_err != nil {
+		return nil, db_err
+	}
+	if !reserved {
+		stored_hash, stored, db_err := app.st.getIdempotentResponse(ctx, owner, params.IdempotencyKey)
+		if db_err != nil {
+			return nil, db_err
+		}
+		if stored_hash != request_hash {
+			return nil, huma.Error422UnprocessableEntity("Idempotency-Key was already used for a different request.")
+		}
+		if stored == nil {
+			return nil, huma.Error409Conflict("A request with this Idempotency-Key is still in progress.")
+		}
+		var resp T
+		if err := json.Unmarshal(stored, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+
+	resp, err := f()
+	if err != nil {
+		if release_err := app.st.releaseIdempotencyKey(ctx, owner, params.IdempotencyKey); release_err != nil {
+			log.Printf("Could not release Idempotency-Key: %v", release_err)
+		}
+		return nil, err
+	}
+	b, err = json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	if db_err := app.st.saveIdempotentResponse(ctx, owner, params.IdempotencyKey, b); db_err != nil {
+		log.Printf("Could not save response for Idempotency-Key: %v", db_err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/sqids/sqids-go"
+)
+
+// DB_CONTEXT_KEY holds the DB for resolving IDs in request parameters.
+const DB_CONTEXT_KEY = "db"
+
+// Alphabets short IDs are encoded with. Changing these or id_length changes
+// every short ID.
+const (
+	leaderboard_id_alphabet = "0Yich9ZaBszQ6fr3VgCM4kKxdoGu8ybSJjDPeRvX5nNH2wFOpIq1TWA7mtLUlE"
+	submission_id_alphabet  = "BzIwEQb6rHRS47ut1gCJl3Yeaf5dcGsn8UkWL2yqjO0FVmixDpo9MPvhNATZKX"
+)
+
+var id_length = 9
+
+// IDParser encodes the sequence numbers of leaderboards and submissions as
+// short IDs.
+type IDParser struct {
+	submissions  *sqids.Sqids
+	leaderboards *sqids.Sqids
+}
+
+var ids = newIDParser()
+
+func newIDParser() *IDParser {
+	// No blocklist, since a new sqids version could change the default one
+	// and with it existing IDs.
+	leaderboards, err := sqids.New(sqids.Options{Alphabet: leaderboard_id_alphabet, MinLength: uint8(id_length), Blocklist: []string{}})
+	if err != nil {
+		panic(err)
+	}
+	submissions, err := sqids.New(sqids.Options{Alphabet: submission_id_alphabet, MinLength: uint8(id_length), Blocklist: []string{}})
+	if err != nil {
+		panic(err)
+	}
+	return &IDParser{submissions: submissions, leaderboards: leaderboards}
+}
+
+func encodeID(s *sqids.Sqids, seq int64) string {
+	id, _ := s.Encode([]uint64{uint64(seq)})
+	return id
+}
+
+// decodeID returns the sequence number of a short ID, or false if it isn't
+// one. Only the canonical encoding of a number is accepted.
+func decodeID(s *sqids.Sqids, id string) (int64, bool) {
+	nums := s.Decode(id)
+	if len(nums) != 1 || nums[0] > math.MaxInt64 || encodeID(s, int64(nums[0])) != id {
+		return 0, false
+	}
+	return int64(nums[0]), true
+}
+
+// LeaderboardShortID is a leaderboard's sequence number, serialized as its
+// short ID.
+type LeaderboardShortID int64
+
+func (id LeaderboardShortID) String() string {
+	return encodeID(ids.leaderboards, int64(id))
+}
+
+func (id LeaderboardShortID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (id *LeaderboardShortID) UnmarshalText(b []byte) error {
+	seq, ok := decodeID(ids.leaderboards, string(b))
+	if !ok {
+		return huma.Error422UnprocessableEntity("Invalid leaderboard ID.")
+	}
+	*id = LeaderboardShortID(seq)
+	return nil
+}
+
+// SubmissionShortID is a submission's sequence number, serialized as its
+// short ID.
+type SubmissionShortID int64
+
+func (id SubmissionShortID) String() string {
+	return encodeID(ids.submissions, int64(id))
+}
+
+func (id SubmissionShortID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (id *SubmissionShortID) UnmarshalText(b []byte) error {
+	seq, ok := decodeID(ids.submissions, string(b))
+	if !ok {
+		return huma.Error422UnprocessableEntity("Invalid submission ID.")
+	}
+	*id = SubmissionShortID(seq)
+	return nil
+}
+
+// parseID reads a UUID or short ID, returning the zero value for the other.
+func parseID(s *sqids.Sqids, raw string) (uuid.UUID, int64, bool) {
+	if id, err := uuid.FromString(raw); err == nil {
+		return id, 0, true
+	}
+	seq, ok := decodeID(s, raw)
+	return uuid.Nil, seq, ok
+}
+
+// provideDB makes the DB available to parameter resolvers.
+func (app *App) provideDB(ctx huma.Context, next func(huma.Context)) {
+	next(huma.WithValue(ctx, DB_CONTEXT_KEY, app.st))
+}
+
+// resolveID fills in the UUID and sequence number of a path parameter given as
+// either. An ID that doesn't exist keeps whichever was given, so handlers
+// report it missing as they would any other.
+func resolveID(ctx huma.Context, param string, raw string, s *sqids.Sqids, table string, id *uuid.UUID, seq *int64) []error {
+	var ok bool
+	if *id, *seq, ok = parseID(s, raw); !ok {
+		return []error{&huma.ErrorDetail{
+			Message:  "not a valid ID",
+			Location: "path." + param,
+			Value:    raw,
+		}}
+	}
+	st, ok := ctx.Context().Value(DB_CONTEXT_KEY).(DB)
+	if !ok {
+		return nil
+	}
+	if db_err := st.resolveID(ctx.Context(), table, id, seq); db_err != nil {
+		return []error{huma.Error500InternalServerError("Could not look up ID.", db_err)}
+	}
+	return nil
+}
+
+// Resolve also checks the leaderboard is visible to the request, so every
+// route taking a leaderboard ID hides private leaderboards.
+func (p *LeaderboardIDParam) Resolve(ctx huma.Context) []error {
+	var errs []error
+	if isSlug(p.RawID) {
+		errs = resolveSlug(ctx, p.RawID, &p.ID, (*int64)(&p.ShortID))
+	} else {
+		errs = resolveID(ctx, "leaderboard_id", p.RawID, ids.leaderboards, "leaderboards", &p.ID, (*int64)(&p.ShortID))
+	}
+	st, ok := ctx.Context().Value(DB_CONTEXT_KEY).(DB)
+	if len(errs) > 0 || !ok || p.ID.IsNil() {
+		return errs
+	}
+	_, uuid_err := uuid.FromString(p.RawID)
+	by_short_id := !isSlug(p.RawID) && uuid_err != nil
+	if err := requireLeaderboardAccess(ctx.Context(), st, ctx.Method(), p.ID, by_short_id); err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+func (p *SubmissionIDParam) Resolve(ctx huma.Context) []error {
+	return resolveID(ctx, "submission_id", p.RawSubmissionID, ids.submissions, "submissions", &p.SubmissionID, (*int64)(&p.SubmissionShortID))
+}
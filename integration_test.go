@@ -16,7 +16,6 @@ import (
 	"time"
 
 	"github.com/danielgtaylor/huma/v2/humatest"
-	"github.com/gofrs/uuid/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 )
@@ -38,6 +37,7 @@ func setupTestData(ctx context.Context, signing_key string, tx pgx.Tx) (App, Tes
 	testCtx.users["player3"] = "meow-player3"
 	testCtx.users["Anonymous1"] = "meow-Anonymous"
 	testCtx.users["Anonymous2"] = "meow-Anonymous2"
+	testCtx.users["expired"] = "meow-expired"
 
 	db := DB{
 		conn: tx,
@@ -49,17 +49,48 @@ func setupTestData(ctx context.Context, signing_key string, tx pgx.Tx) (App, Tes
 	db.createTestUser(ctx, testCtx.users["player3"], "player3", "admin3@admin.admin", false, CustomerInfo{id: ls_test_ids[2], subscription_id: ls_subscription_ids[2]})
 	db.createTestUser(ctx, testCtx.users["Anonymous1"], "Anonymous1", "s@anonymous.anonymous", true, CustomerInfo{id: ls_test_ids[3], subscription_id: ls_subscription_ids[3]})
 	db.createTestUser(ctx, testCtx.users["Anonymous2"], "Anonymous2", "s2@anonymous.anonymous", true, CustomerInfo{id: ls_test_ids[4], subscription_id: ls_subscription_ids[4]})
+	db.createTestUser(ctx, testCtx.users["expired"], "expired", "expired@admin.admin", false, CustomerInfo{id: ls_test_ids[4] + 1, subscription_id: ls_subscription_ids[4] + 1})
+
+	for name, id := range testCtx.users {
+		expires_at := time.Now().Add(time.Hour)
+		if name == "expired" {
+			expires_at = time.Now().Add(-time.Hour)
+		}
+		if err := db.createTestSession(ctx, id, sessionToken(id), expires_at); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	app := App{
-		st:          db,
-		webhookHash: hmac.New(sha256.New, []byte(signing_key)),
-		cache:       initCache(),
+		st:                db,
+		webhookHash:       hmac.New(sha256.New, []byte(signing_key)),
+		cache:             initCache(),
+		events:            NewBroker(),
+		idempotencyWindow: default_idempotency_window,
+
+		webhookAddrAllowed: publicAddress,
 	}
 	return app, testCtx
 }
 
+func sessionToken(user_id string) string {
+	return fmt.Sprintf("token-%s", user_id)
+}
+
+func authHeader(user_id string) string {
+	return fmt.Sprintf("Authorization: Bearer %s", sessionToken(user_id))
+}
+
+func newTestDB(t testing.TB) DB {
+	t.Helper()
+	if err := runMigrations(t.Context(), os.Getenv("DB_URL")); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	return NewDBConn(t.Context(), os.Getenv("DB_URL"))
+}
+
 func setupBenchmarkApi(t *testing.B) TestContext {
-	db := NewDBConn(t.Context(), os.Getenv("DB_URL"))
+	db := newTestDB(t)
 
 	test_tx, err := db.conn.Begin(t.Context())
 	if err != nil {
@@ -73,9 +104,9 @@ func setupBenchmarkApi(t *testing.B) TestContext {
 	return testData
 }
 
-func benchmarkCreateBasicLeaderboard(api humatest.TestAPI, b *testing.B, userid string) uuid.UUID {
+func benchmarkCreateBasicLeaderboard(api humatest.TestAPI, b *testing.B, userid string) LeaderboardShortID {
 	resp := api.Post("/leaderboard",
-		fmt.Sprintf("UserID: %s", userid),
+		authHeader(userid),
 		map[string]any{
 			"title":         "My First Leaderboard",
 			"duration":      "24:01:00",
@@ -90,10 +121,10 @@ func benchmarkCreateBasicLeaderboard(api humatest.TestAPI, b *testing.B, userid
 	return newResp.Id
 }
 
-func createDefaultLeaderboard(t *testing.T, api humatest.TestAPI, userid string) uuid.UUID {
+func createDefaultLeaderboard(t *testing.T, api humatest.TestAPI, userid string) LeaderboardShortID {
 	t.Helper()
 	resp := api.Post("/leaderboard",
-		fmt.Sprintf("UserID: %s", userid),
+		authHeader(userid),
 		map[string]any{
 			"title":         "My Default Leaderboard",
 			"highest_first": true,
@@ -107,11 +138,11 @@ func createDefaultLeaderboard(t *testing.T, api humatest.TestAPI, userid string)
 	return newResp.Id
 }
 
-func createLeaderboardTimeLimit(t *testing.T, api humatest.TestAPI, userid string, start, stop string) uuid.UUID {
+func createLeaderboardTimeLimit(t *testing.T, api humatest.TestAPI, userid string, start, stop string) LeaderboardShortID {
 	t.Helper()
 
 	resp := api.Post("/leaderboard",
-		fmt.Sprintf("UserID: %s", userid),
+		authHeader(userid),
 		map[string]any{
 			"title":         "My First Leaderboard",
 			"highest_first": true,
@@ -126,11 +157,11 @@ func createLeaderboardTimeLimit(t *testing.T, api humatest.TestAPI, userid strin
 	return newResp.Id
 }
 
-func createVerifiedLeaderboard(t *testing.T, api humatest.TestAPI, userid string) uuid.UUID {
+func createVerifiedLeaderboard(t *testing.T, api humatest.TestAPI, userid string) LeaderboardShortID {
 	t.Helper()
 
 	resp := api.Post("/leaderboard",
-		fmt.Sprintf("UserID: %s", userid),
+		authHeader(userid),
 		map[string]any{
 			"title":         "My First Leaderboard",
 			"stop":          time.Now().AddDate(0, 1, 0).Format(time.RFC3339),
@@ -144,11 +175,11 @@ func createVerifiedLeaderboard(t *testing.T, api humatest.TestAPI, userid string
 	json.Unmarshal(resp.Body.Bytes(), &newResp)
 	return newResp.Id
 }
-func createBasicLeaderboard(t *testing.T, api humatest.TestAPI, userid string) uuid.UUID {
+func createBasicLeaderboard(t *testing.T, api humatest.TestAPI, userid string) LeaderboardShortID {
 	t.Helper()
 
 	resp := api.Post("/leaderboard",
-		fmt.Sprintf("UserID: %s", userid),
+		authHeader(userid),
 		map[string]any{
 			"title":         "My First Leaderboard",
 			"stop":          time.Now().AddDate(0, 1, 0).Format(time.RFC3339),
@@ -186,11 +217,11 @@ func Benchmark10Leaderboards10000Submissions(b *testing.B) {
 func benchmarkGetLeaderboard(l_count, s_count int, b *testing.B) {
 	test_ctx := setupBenchmarkApi(b)
 	api := test_ctx.api
-	l_ids := []uuid.UUID{}
+	l_ids := []LeaderboardShortID{}
 
 	for range l_count {
 		id := benchmarkCreateBasicLeaderboard(api, b, test_ctx.users["Anonymous"])
-		if id.IsNil() {
+		if id == 0 {
 			b.FailNow()
 		}
 		l_ids = append(l_ids, id)
@@ -199,7 +230,7 @@ func benchmarkGetLeaderboard(l_count, s_count int, b *testing.B) {
 
 		postResp := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", l_ids[rand.IntN(len(l_ids))]),
-			fmt.Sprintf("UserID: %s", test_ctx.users["player2"]),
+			authHeader(test_ctx.users["player2"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": rand.IntN(500000000),
@@ -217,7 +248,7 @@ func benchmarkGetLeaderboard(l_count, s_count int, b *testing.B) {
 }
 
 func WithTx(t *testing.T, f func(ctx context.Context, tx pgx.Tx)) {
-	db := NewDBConn(t.Context(), os.Getenv("DB_URL"))
+	db := newTestDB(t)
 	test_tx, err := db.conn.Begin(t.Context())
 	if err != nil {
 		log.Fatal("Failed to setup transaction:", err)
@@ -229,7 +260,7 @@ func WithTx(t *testing.T, f func(ctx context.Context, tx pgx.Tx)) {
 
 func WithAppSigningKey(t *testing.T, signing_key string, f func(ctx context.Context, api humatest.TestAPI, users map[string]string)) {
 	t.Helper()
-	db := NewDBConn(t.Context(), os.Getenv("DB_URL"))
+	db := newTestDB(t)
 
 	test_tx, err := db.conn.Begin(t.Context())
 	if err != nil {
@@ -249,7 +280,15 @@ func WithAppSigningKey(t *testing.T, signing_key string, f func(ctx context.Cont
 
 func WithApp(t *testing.T, f func(ctx context.Context, api humatest.TestAPI, users map[string]string)) {
 	t.Helper()
-	db := NewDBConn(t.Context(), os.Getenv("DB_URL"))
+	WithAppState(t, func(ctx context.Context, app *App, api humatest.TestAPI, users map[string]string) {
+		f(ctx, api, users)
+	})
+}
+
+// WithAppState is WithApp for tests that also need the App, e.g. to publish events.
+func WithAppState(t *testing.T, f func(ctx context.Context, app *App, api humatest.TestAPI, users map[string]string)) {
+	t.Helper()
+	db := newTestDB(t)
 
 	test_tx, err := db.conn.Begin(t.Context())
 	if err != nil {
@@ -261,7 +300,7 @@ func WithApp(t *testing.T, f func(ctx context.Context, api humatest.TestAPI, use
 	_, api := humatest.New(t)
 	app.addRoutes(api)
 
-	f(t.Context(), api, testData.users)
+	f(t.Context(), &app, api, testData.users)
 
 	test_tx.Rollback(t.Context())
 
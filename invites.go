@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// How long invite codes work for when no expiry is given.
+const default_invite_expiry = 7 * 24 * time.Hour
+
+func newInviteCode() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requireEntrant returns a 403 if player can't submit to the leaderboard
+// because it only accepts scores from members.
+func (app *App) requireEntrant(ctx context.Context, leaderboard uuid.UUID, player string) error {
+	allowed, db_err := app.st.canSubmit(ctx, leaderboard, player)
+	if db_err == pgx.ErrNoRows {
+		return huma.Error404NotFound("Leaderboard not found.")
+	}
+	if db_err != nil {
+		return db_err
+	}
+	if !allowed {
+		return huma.Error403Forbidden("Only members can submit to this leaderboard.")
+	}
+	return nil
+}
+
+func (app *App) addInvite(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	NewInviteBody
+}) (*InviteResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	expires_at := input.Body.ExpiresAt
+	if expires_at == nil {
+		expiry := time.Now().Add(default_invite_expiry)
+		expires_at = &expiry
+	}
+	if !expires_at.After(time.Now()) {
+		return nil, huma.Error422UnprocessableEntity("expires_at must be in the future.")
+	}
+
+	invite, db_err := app.st.newInvite(ctx, input.ID, user.ID, newInviteCode(), input.Body.MaxUses, expires_at)
+	if db_err != nil {
+		return nil, db_err
+	}
+	return &InviteResponse{Body: invite}, nil
+}
+
+func (app *App) getInvites(ctx context.Context, input *struct {
+	LeaderboardIDParam
+}) (*InvitesResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	invites, db_err := app.st.getInvites(ctx, input.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	resp := &InvitesResponse{}
+	resp.Body.Invites = invites
+	return resp, nil
+}
+
+func (app *App) revokeInvite(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	InviteIDParam
+}) (*MessageResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	count, db_err := app.st.revokeInvite(ctx, input.ID, input.InviteID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if count == 0 {
+		return nil, huma.Error404NotFound("Invite not found.")
+	}
+	resp := &MessageResponse{}
+	resp.Body.Message = "Invite revoked."
+	return resp, nil
+}
+
+// redeemInvite joins the leaderboard an invite code is for. It isn't under
+// the leaderboard's path, so codes for private leaderboards can be redeemed by
+// users who can't see them yet.
+func (app *App) redeemInvite(ctx context.Context, input *struct {
+	InviteCodeParam
+}) (*JoinResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	leaderboard, joined, db_err := app.st.redeemInvite(ctx, input.Code, user.ID)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Invite code is invalid, expired or used up.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+	resp := &JoinResponse{Status: http.StatusOK}
+	resp.Body.LeaderboardID = leaderboard
+	resp.Body.Status = "member"
+	if joined {
+		resp.Body.Status = "joined"
+	}
+	return resp, nil
+}
+
+// joinLeaderboard asks to join a leaderboard that approves its entrants.
+func (app *App) joinLeaderboard(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	JoinBody
+}) (*JoinResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, db_err := app.st.getLeaderboardInfo(ctx, input.ID)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("Leaderboard not found.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+	if info.Entry == "open" {
+		return nil, huma.Error400BadRequest("Anyone can submit to this leaderboard.")
+	}
+
+	resp := &JoinResponse{Status: http.StatusOK}
+	resp.Body.LeaderboardID = input.ShortID
+	resp.Body.Status = "member"
+	member, db_err := app.st.canSubmit(ctx, input.ID, user.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if member {
+		return resp, nil
+	}
+	if info.Entry != "approval" {
+		return nil, huma.Error403Forbidden("This leaderboard can only be joined with an invite code.")
+	}
+	if db_err := app.st.requestToJoin(ctx, input.ID, user.ID, input.Body.Message); db_err != nil {
+		return nil, db_err
+	}
+	resp.Status = http.StatusAccepted
+	resp.Body.Status = "pending"
+	return resp, nil
+}
+
+func (app *App) getJoinRequests(ctx context.Context, input *struct {
+	LeaderboardIDParam
+}) (*JoinRequestsResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	requests, db_err := app.st.getJoinRequests(ctx, input.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	resp := &JoinRequestsResponse{}
+	resp.Body.Requests = requests
+	return resp, nil
+}
+
+func (app *App) answerJoinRequest(ctx context.Context, leaderboard LeaderboardIDParam, user_id string, approved bool) (*MessageResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, leaderboard.ID, user); err != nil {
+		return nil, err
+	}
+	requester, db_err := app.st.findUser(ctx, user_id, "")
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("User not found.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+	count, db_err := app.st.answerJoinRequest(ctx, leaderboard.ID, requester.ID, approved, user.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if count == 0 {
+		return nil, huma.Error404NotFound("Join request not found.")
+	}
+	resp := &MessageResponse{}
+	resp.Body.Message = "Join request denied."
+	if approved {
+		resp.Body.Message = "Join request approved."
+	}
+	return resp, nil
+}
+
+func (app *App) approveJoinRequest(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	UserIDParam
+}) (*MessageResponse, error) {
+	return app.answerJoinRequest(ctx, input.LeaderboardIDParam, input.UserID, true)
+}
+
+func (app *App) denyJoinRequest(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	UserIDParam
+}) (*MessageResponse, error) {
+	return app.answerJoinRequest(ctx, input.LeaderboardIDParam, input.UserID, false)
+}
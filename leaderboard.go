@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"hash"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"time"
@@ -15,12 +16,10 @@ import (
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/adapters/humachi"
 	"github.com/danielgtaylor/huma/v2/humacli"
+	"github.com/danielgtaylor/huma/v2/sse"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
-	"github.com/gofrs/uuid/v5"
-	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/spf13/cobra"
-	"github.com/sqids/sqids-go"
 )
 
 type CloudConfigs struct {
@@ -34,12 +33,6 @@ type CloudConfigs struct {
 var VERSION string
 var CLI = ""
 var DOCS = ""
-var id_length = 9
-
-type IDParser struct {
-	submissions  *sqids.Sqids
-	leaderboards *sqids.Sqids
-}
 
 func OpenAPIGenConfig() huma.Config {
 	config := huma.DefaultConfig("leaderapi", VERSION)
@@ -50,6 +43,13 @@ func OpenAPIGenConfig() huma.Config {
 	config.Servers = []*huma.Server{
 		{URL: url},
 	}
+	config.Components.SecuritySchemes = map[string]*huma.SecurityScheme{
+		"bearer": {
+			Type:        "http",
+			Scheme:      "bearer",
+			Description: "Session token issued at sign in, or a leaderboard API key starting with tk_.",
+		},
+	}
 
 	return config
 }
@@ -62,10 +62,27 @@ type App struct {
 	api         huma.API
 	webhookHash hash.Hash
 	lsApiKey    string
-	cache       *lru.TwoQueueCache[uuid.UUID, *LeaderboardResponse]
+	cache       *LeaderboardCache
+	events      *Broker
+	// Client used to send outbound webhooks.
+	webhookClient *http.Client
+	// Reports whether outbound webhooks may be sent to an address.
+	webhookAddrAllowed func(net.IP) bool
+	// How long responses to requests with an Idempotency-Key are replayed.
+	idempotencyWindow time.Duration
+}
+
+// withModifiers applies operation modifiers such as app.authenticated to an
+// operation, for registrations that don't take them directly.
+func withModifiers(op huma.Operation, modifiers ...func(o *huma.Operation)) huma.Operation {
+	for _, modify := range modifiers {
+		modify(&op)
+	}
+	return op
 }
 
 func (app *App) addRoutes(api huma.API) {
+	api.UseMiddleware(app.provideDB)
 	huma.Get(api, "/health", app.healthCheck)
 
 	// Leaderboards
@@ -73,29 +90,86 @@ func (app *App) addRoutes(api huma.API) {
 		OperationID: "new-leaderboard",
 		Method:      http.MethodPost,
 		Path:        "/leaderboard",
-		Middlewares: huma.Middlewares{app.CustomerMiddleware},
+		Middlewares: huma.Middlewares{app.AuthMiddleware, app.CustomerMiddleware},
+		Security:    []map[string][]string{{"bearer": {}}},
 	}, app.postNewLeaderboard)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, withModifiers(huma.Operation{
 		OperationID: "get-leaderboard",
 		Method:      http.MethodGet,
 		Path:        "/leaderboard/{leaderboard_id}",
-	}, app.getLeaderboard)
-	huma.Get(api, "/leaderboard/{leaderboard_id}/info", app.getLeaderboardInfo)
-	huma.Get(api, "/leaderboard/{leaderboard_id}/verifiers", app.getLeaderboardVerifiers)
+	}, app.optionallyAuthenticated), app.getLeaderboard)
+	huma.Patch(api, "/leaderboard/{leaderboard_id}", app.updateLeaderboard, app.authenticated)
+	huma.Delete(api, "/leaderboard/{leaderboard_id}", app.deleteLeaderboard, app.authenticated)
+	huma.Post(api, "/leaderboard/{leaderboard_id}/archive", app.archiveLeaderboard, app.authenticated)
+	huma.Put(api, "/leaderboard/{leaderboard_id}/slug", app.setLeaderboardSlug, app.authenticated)
+	huma.Register(api, withModifiers(huma.Operation{
+		OperationID:   "get-leaderboard-by-slug",
+		Method:        http.MethodGet,
+		Path:          "/l/{slug}",
+		Summary:       "Redirect a slug to its leaderboard",
+		Description:   "Redirects to the leaderboard a current or previous slug belongs to.",
+		DefaultStatus: http.StatusPermanentRedirect,
+	}, app.optionallyAuthenticated), app.redirectSlug)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/info", app.getLeaderboardInfo, app.optionallyAuthenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/verifiers", app.getLeaderboardVerifiers, app.optionallyAuthenticated)
+	huma.Post(api, "/leaderboard/{leaderboard_id}/verifiers", app.addLeaderboardVerifier, app.authenticated)
+	huma.Delete(api, "/leaderboard/{leaderboard_id}/verifiers/{verifier}", app.removeLeaderboardVerifier, app.authenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/members", app.getLeaderboardMembers, app.authenticated)
+	huma.Post(api, "/leaderboard/{leaderboard_id}/members", app.addLeaderboardMember, app.authenticated)
+	huma.Delete(api, "/leaderboard/{leaderboard_id}/members/{user_id}", app.removeLeaderboardMember, app.authenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/verifiers/history", app.getLeaderboardVerifierHistory, app.authenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/rank/{user_id}", app.getUserRank, app.optionallyAuthenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/period/{period}", app.getLeaderboardPeriod, app.optionallyAuthenticated)
+	sse.Register(api, withModifiers(huma.Operation{
+		OperationID: "stream-leaderboard",
+		Method:      http.MethodGet,
+		Path:        "/leaderboard/{leaderboard_id}/stream",
+		Summary:     "Stream leaderboard changes",
+		Description: "Sends the current rankings, then a submission event and refreshed rankings whenever a submission is added, edited, verified or withdrawn.",
+		Middlewares: huma.Middlewares{app.leaderboardExists},
+	}, app.optionallyAuthenticated), map[string]any{
+		"rankings":   LeaderboardResponseBody{},
+		"submission": SubmissionEvent{},
+		"ping":       StreamPing{},
+	}, app.streamLeaderboard)
+
+	huma.Post(api, "/leaderboard/{leaderboard_id}/webhooks", app.addWebhook, app.authenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/webhooks", app.getWebhooks, app.authenticated)
+	huma.Delete(api, "/leaderboard/{leaderboard_id}/webhooks/{webhook_id}", app.removeWebhook, app.authenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/webhooks/{webhook_id}/deliveries", app.getWebhookDeliveries, app.authenticated)
+	huma.Post(api, "/leaderboard/{leaderboard_id}/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver", app.redeliverWebhook, app.authenticated)
+
+	huma.Post(api, "/leaderboard/{leaderboard_id}/keys", app.addAPIKey, app.authenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/keys", app.getAPIKeys, app.authenticated)
+	huma.Delete(api, "/leaderboard/{leaderboard_id}/keys/{key_id}", app.revokeAPIKey, app.authenticated)
+
+	huma.Post(api, "/leaderboard/{leaderboard_id}/invites", app.addInvite, app.authenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/invites", app.getInvites, app.authenticated)
+	huma.Delete(api, "/leaderboard/{leaderboard_id}/invites/{invite_id}", app.revokeInvite, app.authenticated)
+	huma.Post(api, "/invites/{code}", app.redeemInvite, app.authenticated)
+	huma.Post(api, "/leaderboard/{leaderboard_id}/join", app.joinLeaderboard, app.authenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/join-requests", app.getJoinRequests, app.authenticated)
+	huma.Post(api, "/leaderboard/{leaderboard_id}/join-requests/{user_id}", app.approveJoinRequest, app.authenticated)
+	huma.Delete(api, "/leaderboard/{leaderboard_id}/join-requests/{user_id}", app.denyJoinRequest, app.authenticated)
+
+	huma.Post(api, "/leaderboard/{leaderboard_id}/signing-secret", app.rotateSubmissionSecret, app.authenticated)
+	huma.Delete(api, "/leaderboard/{leaderboard_id}/signing-secret", app.removeSubmissionSecret, app.authenticated)
 
 	// Submissions
-	huma.Post(api, "/leaderboard/{leaderboard_id}/submission", app.postNewScore)
-	huma.Get(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}", app.getSubmission)
-	huma.Get(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}/history", app.GetSubmissionHistory)
-	// huma.Patch(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}/score", app.updateSubmission)
-	huma.Patch(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}/verify", app.VerifyScore)
-	huma.Post(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}/comment", app.AddSubmissionComment)
+	huma.Post(api, "/leaderboard/{leaderboard_id}/submission", app.postNewScore, app.authenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}", app.getSubmission, app.optionallyAuthenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}/history", app.GetSubmissionHistory, app.optionallyAuthenticated)
+	huma.Get(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}/around", app.getSubmissionsAround, app.optionallyAuthenticated)
+	huma.Patch(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}", app.updateSubmission, app.authenticated)
+	huma.Delete(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}", app.withdrawSubmission, app.authenticated)
+	huma.Patch(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}/verify", app.VerifyScore, app.authenticated)
+	huma.Post(api, "/leaderboard/{leaderboard_id}/submission/{submission_id}/comment", app.AddSubmissionComment, app.authenticated)
 
 	// Accounts
-	huma.Get(api, "/account/{user_id}/leaderboards", app.getAccountLeaderboards)
-	huma.Get(api, "/account/{user_id}/submissions", app.getAccountSubmissions)
-	huma.Post(api, "/account/link_anonymous", app.linkAnonymousAccount)
+	huma.Get(api, "/account/{user_id}/leaderboards", app.getAccountLeaderboards, app.optionallyAuthenticated)
+	huma.Get(api, "/account/{user_id}/submissions", app.getAccountSubmissions, app.optionallyAuthenticated)
+	huma.Post(api, "/account/link_anonymous", app.linkAnonymousAccount, app.authenticated)
 
 	// Webhooks
 
@@ -114,6 +188,8 @@ type Options struct {
 	Debug bool   `doc:"Enable debug logging"`
 	Host  string `doc:"Hostname to listen on."`
 	Port  int    `doc:"Port to listen on." short:"p" default:"8888"`
+
+	IdempotencyWindow time.Duration `doc:"How long responses to requests with an Idempotency-Key are replayed." default:"24h"`
 }
 
 func main() {
@@ -124,6 +200,11 @@ func main() {
 		webhookHash: hmac.New(sha256.New, []byte(ls_secret)),
 		lsApiKey:    api_key,
 		cache:       initCache(),
+		events:      NewBroker(),
+
+		webhookClient:      newWebhookClient(publicAddress),
+		webhookAddrAllowed: publicAddress,
+		idempotencyWindow:  default_idempotency_window,
 	}
 
 	r := chi.NewMux()
@@ -133,7 +214,7 @@ func main() {
 		AllowedOrigins: []string{"https://*", "http://*"},
 		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
 		AllowedMethods:   []string{"GET", "PATCH", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Signature", "X-Event-Name"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Signature", "X-Event-Name", "X-Submission-Signature", "X-Submission-Nonce", "X-Submission-Timestamp", "Idempotency-Key"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
@@ -151,10 +232,23 @@ func main() {
 			Addr:    fmt.Sprintf(":%d", opts.Port),
 			Handler: r,
 		}
+		background_ctx, stop_background := context.WithCancel(context.Background())
+		app.idempotencyWindow = opts.IdempotencyWindow
 
 		hooks.OnStart(func() {
 			// Start your server here
+			if err := runMigrations(context.Background(), db_url); err != nil {
+				log.Fatal(err)
+			}
 			app.st = NewDBConn(context.Background(), db_url)
+			app.cache.bus = app.st
+			go app.runScheduler(background_ctx)
+			go app.runWebhooks(background_ctx)
+
+			listener := NewListener(db_url)
+			listener.Handle(leaderboard_events_channel, app.events.PublishPayload)
+			listener.Handle(cache_invalidation_channel, app.cache.Invalidate)
+			go listener.Run(background_ctx)
 
 			if err := http.ListenAndServe(":"+port, r); err != nil {
 				log.Fatal(err)
@@ -165,6 +259,7 @@ func main() {
 			// Gracefully shutdown your server here
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
+			stop_background()
 			server.Shutdown(ctx)
 		})
 	})
@@ -178,5 +273,6 @@ func main() {
 			}
 			fmt.Println(string(b))
 		}})
+	cli.Root().AddCommand(migrateCommand(db_url))
 	cli.Run()
 }
@@ -4,11 +4,13 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,7 +19,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func getSubmissionHistory(t *testing.T, api humatest.TestAPI, leaderboard_id uuid.UUID, submission uuid.UUID) (HistoryResponseBody, *httptest.ResponseRecorder) {
+func getSubmissionHistory(t *testing.T, api humatest.TestAPI, leaderboard_id LeaderboardShortID, submission SubmissionShortID) (HistoryResponseBody, *httptest.ResponseRecorder) {
 	t.Helper()
 	getResp := api.Get(fmt.Sprintf("/leaderboard/%s/submission/%s/history", leaderboard_id, submission))
 	var lResp HistoryResponseBody
@@ -25,7 +27,7 @@ func getSubmissionHistory(t *testing.T, api humatest.TestAPI, leaderboard_id uui
 	return lResp, getResp
 }
 
-func getLeaderboardWithModifiedHeader(t *testing.T, api humatest.TestAPI, leaderboard_id uuid.UUID, if_modified_since time.Time) (LeaderboardResponseBody, *httptest.ResponseRecorder) {
+func getLeaderboardWithModifiedHeader(t *testing.T, api humatest.TestAPI, leaderboard_id LeaderboardShortID, if_modified_since time.Time) (LeaderboardResponseBody, *httptest.ResponseRecorder) {
 
 	t.Helper()
 	getResp := api.Get(fmt.Sprintf("/leaderboard/%s", leaderboard_id),
@@ -35,14 +37,14 @@ func getLeaderboardWithModifiedHeader(t *testing.T, api humatest.TestAPI, leader
 	return lResp, getResp
 }
 
-func getVerifiers(t *testing.T, api humatest.TestAPI, leaderboard_id uuid.UUID) (LeaderboardVerifiersResponseBody, *httptest.ResponseRecorder) {
+func getVerifiers(t *testing.T, api humatest.TestAPI, leaderboard_id LeaderboardShortID) (LeaderboardVerifiersResponseBody, *httptest.ResponseRecorder) {
 	t.Helper()
 	getResp := api.Get(fmt.Sprintf("/leaderboard/%s/verifiers", leaderboard_id))
 	var lResp LeaderboardVerifiersResponseBody
 	json.Unmarshal(getResp.Body.Bytes(), &lResp)
 	return lResp, getResp
 }
-func getLeaderboardInfo(t *testing.T, api humatest.TestAPI, leaderboard_id uuid.UUID) (LeaderboardInfo, *httptest.ResponseRecorder) {
+func getLeaderboardInfo(t *testing.T, api humatest.TestAPI, leaderboard_id LeaderboardShortID) (LeaderboardInfo, *httptest.ResponseRecorder) {
 	t.Helper()
 	getResp := api.Get(fmt.Sprintf("/leaderboard/%s/info", leaderboard_id))
 	var lResp LeaderboardInfo
@@ -50,7 +52,7 @@ func getLeaderboardInfo(t *testing.T, api humatest.TestAPI, leaderboard_id uuid.
 	return lResp, getResp
 }
 
-func getLeaderboard(t testing.TB, api humatest.TestAPI, leaderboard_id uuid.UUID) (LeaderboardResponseBody, *httptest.ResponseRecorder) {
+func getLeaderboard(t testing.TB, api humatest.TestAPI, leaderboard_id LeaderboardShortID) (LeaderboardResponseBody, *httptest.ResponseRecorder) {
 	t.Helper()
 	getResp := api.Get(fmt.Sprintf("/leaderboard/%s", leaderboard_id))
 	var lResp LeaderboardResponseBody
@@ -109,7 +111,7 @@ func TestUnverifiedLeaderboard(t *testing.T) {
 
 		postResp := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 9,
@@ -119,13 +121,13 @@ func TestUnverifiedLeaderboard(t *testing.T) {
 
 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 1, len(lResp.Scores))
-			assert.Equal(t, 9, lResp.Scores[0].Score)
+			assert.Equal(t, "9", lResp.Scores[0].Score.String())
 			assert.Nil(t, lResp.Scores[0].Verified)
 		}
 
 		postResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 10,
@@ -135,15 +137,15 @@ func TestUnverifiedLeaderboard(t *testing.T) {
 
 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 2, len(lResp.Scores))
-			assert.Equal(t, 10, lResp.Scores[0].Score)
-			assert.Equal(t, 9, lResp.Scores[1].Score)
+			assert.Equal(t, "10", lResp.Scores[0].Score.String())
+			assert.Equal(t, "9", lResp.Scores[1].Score.String())
 			assert.Nil(t, lResp.Scores[0].Verified)
 			assert.Nil(t, lResp.Scores[1].Verified)
 		}
 
 		postResp4 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 11,
@@ -153,9 +155,9 @@ func TestUnverifiedLeaderboard(t *testing.T) {
 
 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 3, len(lResp.Scores))
-			assert.Equal(t, 11, lResp.Scores[0].Score)
-			assert.Equal(t, 10, lResp.Scores[1].Score)
-			assert.Equal(t, 9, lResp.Scores[2].Score)
+			assert.Equal(t, "11", lResp.Scores[0].Score.String())
+			assert.Equal(t, "10", lResp.Scores[1].Score.String())
+			assert.Equal(t, "9", lResp.Scores[2].Score.String())
 
 			assert.Nil(t, lResp.Scores[0].Verified)
 			assert.Nil(t, lResp.Scores[1].Verified)
@@ -169,7 +171,7 @@ func TestLeaderboardUpdateTrigger(t *testing.T) {
 		id := createBasicLeaderboard(t, api, users["player2"])
 		postResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 10,
@@ -183,10 +185,83 @@ func TestLeaderboardUpdateTrigger(t *testing.T) {
 	})
 }
 
+func TestConditionalLeaderboardGet(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 10,
+			})
+		if !assert.Equal(t, 200, postResp.Code) {
+			return
+		}
+		var submission SubmissionResponseBody
+		json.Unmarshal(postResp.Body.Bytes(), &submission)
+
+		path := fmt.Sprintf("/leaderboard/%s", id)
+		getResp := api.Get(path)
+		assert.Equal(t, 200, getResp.Code)
+		etag := getResp.Header().Get("ETag")
+		last_modified := getResp.Header().Get("Last-Modified")
+		assert.NotEmpty(t, etag)
+		_, err := http.ParseTime(last_modified)
+		assert.NoError(t, err)
+
+		// The cached copy carries the same validators.
+		cachedResp := api.Get(path)
+		assert.Equal(t, etag, cachedResp.Header().Get("ETag"))
+		assert.Equal(t, last_modified, cachedResp.Header().Get("Last-Modified"))
+
+		matchResp := api.Get(path, "If-None-Match: "+etag)
+		assert.Equal(t, http.StatusNotModified, matchResp.Code)
+		assert.Equal(t, etag, matchResp.Header().Get("ETag"))
+		assert.Empty(t, matchResp.Body.Bytes())
+
+		listResp := api.Get(path, `If-None-Match: "stale", W/`+etag)
+		assert.Equal(t, http.StatusNotModified, listResp.Code)
+
+		// If-Modified-Since is ignored when If-None-Match is sent.
+		mismatchResp := api.Get(path, `If-None-Match: "stale"`, "If-Modified-Since: "+time.Now().AddDate(0, 1, 0).UTC().Format(http.TimeFormat))
+		assert.Equal(t, 200, mismatchResp.Code)
+
+		sameSecondResp := api.Get(path, "If-Modified-Since: "+last_modified)
+		assert.Equal(t, http.StatusNotModified, sameSecondResp.Code)
+
+		badDateResp := api.Get(path, "If-Modified-Since: yesterday")
+		assert.Equal(t, 200, badDateResp.Code)
+
+		api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player1"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 20,
+			})
+		changedResp := api.Get(path, "If-None-Match: "+etag)
+		assert.Equal(t, 200, changedResp.Code)
+		assert.NotEqual(t, etag, changedResp.Header().Get("ETag"))
+
+		for _, p := range []string{
+			fmt.Sprintf("/leaderboard/%s/info", id),
+			fmt.Sprintf("/leaderboard/%s/verifiers", id),
+			fmt.Sprintf("/leaderboard/%s/submission/%s/history", id, submission.ID),
+		} {
+			resp := api.Get(p)
+			if assert.Equal(t, 200, resp.Code, p) && assert.NotEmpty(t, resp.Header().Get("ETag"), p) {
+				resp = api.Get(p, "If-None-Match: "+resp.Header().Get("ETag"))
+				assert.Equal(t, http.StatusNotModified, resp.Code, p)
+			}
+		}
+	})
+}
+
 func TestBadTimestamps(t *testing.T) {
 	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
 		resp := api.Post("/leaderboard",
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"title":         "My First Leaderboard",
 				"highest_first": true,
@@ -209,7 +284,7 @@ func TestVerifiedLeaderboard(t *testing.T) {
 
 		postResp := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 9,
@@ -219,13 +294,13 @@ func TestVerifiedLeaderboard(t *testing.T) {
 
 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 1, len(lResp.Scores))
-			assert.Equal(t, 9, lResp.Scores[0].Score)
+			assert.Equal(t, "9", lResp.Scores[0].Score.String())
 			assert.False(t, *lResp.Scores[0].Verified)
 		}
 
 		postResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 10,
@@ -235,15 +310,15 @@ func TestVerifiedLeaderboard(t *testing.T) {
 
 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 2, len(lResp.Scores))
-			assert.Equal(t, 10, lResp.Scores[0].Score)
-			assert.Equal(t, 9, lResp.Scores[1].Score)
+			assert.Equal(t, "10", lResp.Scores[0].Score.String())
+			assert.Equal(t, "9", lResp.Scores[1].Score.String())
 			assert.False(t, *lResp.Scores[0].Verified)
 			assert.False(t, *lResp.Scores[1].Verified)
 		}
 
 		postResp4 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 11,
@@ -253,9 +328,9 @@ func TestVerifiedLeaderboard(t *testing.T) {
 
 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 3, len(lResp.Scores))
-			assert.Equal(t, 11, lResp.Scores[0].Score)
-			assert.Equal(t, 10, lResp.Scores[1].Score)
-			assert.Equal(t, 9, lResp.Scores[2].Score)
+			assert.Equal(t, "11", lResp.Scores[0].Score.String())
+			assert.Equal(t, "10", lResp.Scores[1].Score.String())
+			assert.Equal(t, "9", lResp.Scores[2].Score.String())
 			assert.False(t, *lResp.Scores[0].Verified)
 			assert.False(t, *lResp.Scores[1].Verified)
 			assert.False(t, *lResp.Scores[2].Verified)
@@ -263,3 +338,928 @@ func TestVerifiedLeaderboard(t *testing.T) {
 		}
 	})
 }
+
+func TestUpdateLeaderboard(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+
+		updateResp := api.Patch(
+			fmt.Sprintf("/leaderboard/%s", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"title":         "Renamed Leaderboard",
+				"highest_first": false,
+				"verify":        true,
+			})
+		assert.Equal(t, 200, updateResp.Code)
+
+		if lResp, getResp := getLeaderboardInfo(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, "Renamed Leaderboard", lResp.Title)
+			assert.False(t, lResp.HighestFirst)
+			assert.True(t, lResp.NeedsVerify)
+			assert.NotNil(t, lResp.Stop)
+		}
+
+		badStopResp := api.Patch(
+			fmt.Sprintf("/leaderboard/%s", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"stop": time.Now().AddDate(0, -1, 0).Format(time.RFC3339),
+			})
+		assert.Equal(t, 400, badStopResp.Code)
+
+		clearResp := api.Patch(fmt.Sprintf("/leaderboard/%s", id), authHeader(users["player2"]), map[string]any{"stop": nil})
+		assert.Equal(t, 200, clearResp.Code)
+		if lResp, getResp := getLeaderboardInfo(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Nil(t, lResp.Stop)
+			assert.Equal(t, "Renamed Leaderboard", lResp.Title)
+		}
+	})
+}
+
+func TestUpdateLeaderboardNotOwner(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+
+		updateResp := api.Patch(
+			fmt.Sprintf("/leaderboard/%s", id),
+			authHeader(users["player3"]),
+			map[string]any{
+				"title": "Stolen Leaderboard",
+			})
+		assert.Equal(t, 403, updateResp.Code)
+
+		archiveResp := api.Post(fmt.Sprintf("/leaderboard/%s/archive", id), authHeader(users["player3"]))
+		assert.Equal(t, 403, archiveResp.Code)
+
+		deleteResp := api.Delete(fmt.Sprintf("/leaderboard/%s", id), authHeader(users["player3"]))
+		assert.Equal(t, 403, deleteResp.Code)
+
+		if lResp, getResp := getLeaderboardInfo(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, "My First Leaderboard", lResp.Title)
+			assert.Nil(t, lResp.TimeArchived)
+		}
+	})
+}
+
+func TestArchiveLeaderboard(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createDefaultLeaderboard(t, api, users["player2"])
+
+		archiveResp := api.Post(fmt.Sprintf("/leaderboard/%s/archive", id), authHeader(users["player2"]))
+		assert.Equal(t, 200, archiveResp.Code)
+
+		if lResp, getResp := getLeaderboardInfo(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.NotNil(t, lResp.TimeArchived)
+			if assert.NotNil(t, lResp.Stop) {
+				assert.False(t, lResp.Stop.After(time.Now()))
+			}
+		}
+
+		archiveAgain := api.Post(fmt.Sprintf("/leaderboard/%s/archive", id), authHeader(users["player2"]))
+		assert.Equal(t, 409, archiveAgain.Code)
+
+		// Leaderboards archived before they start never open.
+		upcoming := createLeaderboardTimeLimit(t, api, users["player2"], time.Now().Add(time.Hour).Format(time.RFC3339), time.Now().Add(2*time.Hour).Format(time.RFC3339))
+		assert.Equal(t, 200, api.Post(fmt.Sprintf("/leaderboard/%s/archive", upcoming), authHeader(users["player2"])).Code)
+		if lResp, getResp := getLeaderboardInfo(t, api, upcoming); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, "closed", lResp.State)
+			assert.False(t, lResp.Start.After(time.Now()))
+			if assert.NotNil(t, lResp.Stop) {
+				assert.False(t, lResp.Stop.After(time.Now()))
+			}
+		}
+		reopenResp := api.Patch(fmt.Sprintf("/leaderboard/%s", upcoming), authHeader(users["player2"]), map[string]any{
+			"stop": time.Now().Add(2 * time.Hour).Format(time.RFC3339),
+		})
+		assert.Equal(t, 409, reopenResp.Code)
+	})
+}
+
+func TestDeleteLeaderboard(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+
+		deleteResp := api.Delete(fmt.Sprintf("/leaderboard/%s", id), authHeader(users["player2"]))
+		assert.Equal(t, 200, deleteResp.Code)
+
+		_, getResp := getLeaderboard(t, api, id)
+		assert.Equal(t, 404, getResp.Code)
+
+		_, infoResp := getLeaderboardInfo(t, api, id)
+		assert.Equal(t, 404, infoResp.Code)
+
+		if lResp, getResp := getAccountLeaderboards(t, api, users["player2"]); assert.Equal(t, 200, getResp.Code) {
+			assert.Zero(t, len(lResp.Leaderboards))
+		}
+	})
+}
+
+func getVerifierHistory(t *testing.T, api humatest.TestAPI, leaderboard_id LeaderboardShortID, user_id string) (VerifierHistoryResponseBody, *httptest.ResponseRecorder) {
+	t.Helper()
+	getResp := api.Get(fmt.Sprintf("/leaderboard/%s/verifiers/history", leaderboard_id), authHeader(user_id))
+	var lResp VerifierHistoryResponseBody
+	json.Unmarshal(getResp.Body.Bytes(), &lResp)
+	return lResp, getResp
+}
+
+func TestAddAndRemoveVerifier(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createVerifiedLeaderboard(t, api, users["player2"])
+
+		addResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/verifiers", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"username": "player3",
+			})
+		assert.Equal(t, 200, addResp.Code)
+
+		if lResp, getResp := getVerifiers(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 2, len(lResp.Verifiers))
+		}
+
+		addAgain := api.Post(
+			fmt.Sprintf("/leaderboard/%s/verifiers", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"user_id": users["player3"],
+			})
+		assert.Equal(t, 409, addAgain.Code)
+
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["Anonymous1"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 9,
+			})
+		assert.Equal(t, 200, postResp.Code)
+		var newScoreBody SubmissionResponseBody
+		json.Unmarshal(postResp.Body.Bytes(), &newScoreBody)
+
+		verifyResp := api.Patch(
+			fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, newScoreBody.ID),
+			authHeader(users["player3"]),
+			map[string]any{
+				"is_valid": true,
+			})
+		assert.Equal(t, 200, verifyResp.Code)
+
+		removeResp := api.Delete(
+			fmt.Sprintf("/leaderboard/%s/verifiers/%s", id, users["player3"]),
+			authHeader(users["player2"]))
+		assert.Equal(t, 200, removeResp.Code)
+
+		if lResp, getResp := getVerifiers(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Verifiers))
+			assert.Equal(t, users["player2"], lResp.Verifiers[0].ID)
+		}
+
+		verifyAgain := api.Patch(
+			fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, newScoreBody.ID),
+			authHeader(users["player3"]),
+			map[string]any{
+				"is_valid": false,
+			})
+		assert.Equal(t, 401, verifyAgain.Code)
+
+		_, anonymousResp := getVerifierHistory(t, api, id, "")
+		assert.Equal(t, 401, anonymousResp.Code)
+		_, removedResp := getVerifierHistory(t, api, id, users["player3"])
+		assert.Equal(t, 403, removedResp.Code)
+		if lResp, getResp := getVerifierHistory(t, api, id, users["player2"]); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 3, len(lResp.History))
+			actions := map[string]int{}
+			for _, entry := range lResp.History {
+				actions[entry.Action]++
+				assert.Equal(t, users["player2"], entry.Author.ID)
+			}
+			assert.Equal(t, 2, actions["add"])
+			assert.Equal(t, 1, actions["remove"])
+		}
+	})
+}
+
+func TestVerifierManagementRestrictions(t *testing.T) {
+	WithAppState(t, func(ctx context.Context, app *App, api humatest.TestAPI, users map[string]string) {
+		id := createVerifiedLeaderboard(t, api, users["player2"])
+
+		notOwnerResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/verifiers", id),
+			authHeader(users["player3"]),
+			map[string]any{
+				"user_id": users["player3"],
+			})
+		assert.Equal(t, 403, notOwnerResp.Code)
+
+		removeOwnerResp := api.Delete(
+			fmt.Sprintf("/leaderboard/%s/verifiers/%s", id, users["player2"]),
+			authHeader(users["player2"]))
+		assert.Equal(t, 400, removeOwnerResp.Code)
+
+		unknownResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/verifiers", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"username": "nobody-by-this-name",
+			})
+		assert.Equal(t, 404, unknownResp.Code)
+
+		if lResp, getResp := getVerifiers(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Verifiers))
+		}
+
+		// A username that is another user's ID only matches as a username.
+		impostor := "meow-impostor"
+		assert.NoError(t, app.st.createTestUser(ctx, impostor, users["player3"], "impostor@admin.admin", false, CustomerInfo{id: 5173999, subscription_id: 5173999}))
+		byName := api.Post(fmt.Sprintf("/leaderboard/%s/verifiers", id), authHeader(users["player2"]), map[string]any{"username": users["player3"]})
+		if assert.Equal(t, 200, byName.Code) {
+			assert.Contains(t, byName.Body.String(), impostor)
+			assert.NotContains(t, byName.Body.String(), `"id":"`+users["player3"]+`"`)
+		}
+		assert.Equal(t, 404, api.Delete(fmt.Sprintf("/leaderboard/%s/verifiers/%s", id, users["player3"]), authHeader(users["player2"])).Code)
+		assert.Equal(t, 200, api.Delete(fmt.Sprintf("/leaderboard/%s/verifiers/%s", id, impostor), authHeader(users["player2"])).Code)
+
+		// Verifiers can be removed by username too.
+		assert.Equal(t, 200, api.Post(fmt.Sprintf("/leaderboard/%s/verifiers", id), authHeader(users["player2"]), map[string]any{"username": "player3"}).Code)
+		assert.Equal(t, 200, api.Delete(fmt.Sprintf("/leaderboard/%s/verifiers/player3", id), authHeader(users["player2"])).Code)
+		if lResp, getResp := getVerifiers(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Verifiers))
+		}
+	})
+}
+
+// nextPage returns the path from a `rel="next"` Link header, or "" if there isn't one.
+func nextPage(resp *httptest.ResponseRecorder) string {
+	for _, link := range resp.Header().Values("Link") {
+		if target, ok := strings.CutSuffix(link, `>; rel="next"`); ok {
+			return strings.TrimPrefix(target, "<")
+		}
+	}
+	return ""
+}
+
+func TestLeaderboardPagination(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+
+		for _, score := range []int{10, 30, 20, 20, 40} {
+			postResp := api.Post(
+				fmt.Sprintf("/leaderboard/%s/submission", id),
+				authHeader(users["player2"]),
+				map[string]any{
+					"link":  "www.youtube.com",
+					"score": score,
+				})
+			assert.Equal(t, 200, postResp.Code)
+		}
+
+		seen := []string{}
+		path := fmt.Sprintf("/leaderboard/%s?limit=2", id)
+		for pages := 0; len(path) > 0; pages++ {
+			if !assert.Less(t, pages, 3) {
+				break
+			}
+			getResp := api.Get(path)
+			if !assert.Equal(t, 200, getResp.Code) {
+				break
+			}
+			var lResp LeaderboardResponseBody
+			json.Unmarshal(getResp.Body.Bytes(), &lResp)
+			assert.LessOrEqual(t, len(lResp.Scores), 2)
+			for _, s := range lResp.Scores {
+				seen = append(seen, s.Score.String())
+			}
+			path = nextPage(getResp)
+		}
+		assert.Equal(t, []string{"40", "30", "20", "20", "10"}, seen)
+
+		badCursor := api.Get(fmt.Sprintf("/leaderboard/%s?cursor=not-a-cursor", id))
+		assert.Equal(t, 400, badCursor.Code)
+	})
+}
+
+func TestRankingModes(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		resp := api.Post("/leaderboard",
+			authHeader(users["player2"]),
+			map[string]any{
+				"title":         "Best Per User",
+				"highest_first": true,
+				"ranking_mode":  "best",
+				"start":         time.Now().Format(time.RFC3339),
+			})
+		if !assert.Equal(t, 200, resp.Code) {
+			return
+		}
+		var created NewLeaderboardResponseBody
+		json.Unmarshal(resp.Body.Bytes(), &created)
+		id := created.Id
+
+		if info, infoResp := getLeaderboardInfo(t, api, id); assert.Equal(t, 200, infoResp.Code) {
+			assert.Equal(t, "best", info.RankingMode)
+		}
+
+		submissions := []struct {
+			user  string
+			score int
+		}{
+			{"player2", 10},
+			{"player2", 30},
+			{"player3", 25},
+			{"player2", 20},
+		}
+		for _, s := range submissions {
+			postResp := api.Post(
+				fmt.Sprintf("/leaderboard/%s/submission", id),
+				authHeader(users[s.user]),
+				map[string]any{
+					"link":  "www.youtube.com",
+					"score": s.score,
+				})
+			assert.Equal(t, 200, postResp.Code)
+		}
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) && assert.Equal(t, 2, len(lResp.Scores)) {
+			assert.Equal(t, users["player2"], lResp.Scores[0].User.ID)
+			assert.Equal(t, "30", lResp.Scores[0].Score.String())
+			assert.Equal(t, users["player3"], lResp.Scores[1].User.ID)
+			assert.Equal(t, "25", lResp.Scores[1].Score.String())
+		}
+
+		updateResp := api.Patch(
+			fmt.Sprintf("/leaderboard/%s", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"ranking_mode": "latest",
+			})
+		assert.Equal(t, 200, updateResp.Code)
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) && assert.Equal(t, 2, len(lResp.Scores)) {
+			assert.Equal(t, users["player3"], lResp.Scores[0].User.ID)
+			assert.Equal(t, "25", lResp.Scores[0].Score.String())
+			assert.Equal(t, users["player2"], lResp.Scores[1].User.ID)
+			assert.Equal(t, "20", lResp.Scores[1].Score.String())
+		}
+
+		updateResp = api.Patch(
+			fmt.Sprintf("/leaderboard/%s", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"ranking_mode": "all",
+			})
+		assert.Equal(t, 200, updateResp.Code)
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 4, len(lResp.Scores))
+		}
+
+		badModeResp := api.Patch(
+			fmt.Sprintf("/leaderboard/%s", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"ranking_mode": "worst",
+			})
+		assert.Equal(t, 422, badModeResp.Code)
+	})
+}
+
+func TestTiePolicies(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+
+		if info, infoResp := getLeaderboardInfo(t, api, id); assert.Equal(t, 200, infoResp.Code) {
+			assert.Equal(t, "standard", info.TiePolicy)
+		}
+
+		for _, score := range []int{30, 40, 20, 30} {
+			postResp := api.Post(
+				fmt.Sprintf("/leaderboard/%s/submission", id),
+				authHeader(users["player2"]),
+				map[string]any{
+					"link":  "www.youtube.com",
+					"score": score,
+				})
+			assert.Equal(t, 200, postResp.Code)
+		}
+
+		ranks := func() []int {
+			lResp, getResp := getLeaderboard(t, api, id)
+			assert.Equal(t, 200, getResp.Code)
+			ranks := []int{}
+			for _, s := range lResp.Scores {
+				ranks = append(ranks, s.Rank)
+			}
+			return ranks
+		}
+		assert.Equal(t, []int{1, 2, 2, 4}, ranks())
+
+		for _, c := range []struct {
+			policy   string
+			expected []int
+		}{
+			{"dense", []int{1, 2, 2, 3}},
+			{"earliest", []int{1, 2, 3, 4}},
+			{"standard", []int{1, 2, 2, 4}},
+		} {
+			updateResp := api.Patch(
+				fmt.Sprintf("/leaderboard/%s", id),
+				authHeader(users["player2"]),
+				map[string]any{
+					"tie_policy": c.policy,
+				})
+			assert.Equal(t, 200, updateResp.Code)
+			assert.Equal(t, c.expected, ranks(), c.policy)
+		}
+
+		getResp := api.Get(fmt.Sprintf("/leaderboard/%s?limit=2", id))
+		if assert.Equal(t, 200, getResp.Code) {
+			secondPage := api.Get(nextPage(getResp))
+			var lResp LeaderboardResponseBody
+			json.Unmarshal(secondPage.Body.Bytes(), &lResp)
+			if assert.Equal(t, 2, len(lResp.Scores)) {
+				assert.Equal(t, 2, lResp.Scores[0].Rank)
+				assert.Equal(t, 4, lResp.Scores[1].Rank)
+			}
+		}
+	})
+}
+
+func TestUserRankAndAround(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+
+		submissions := []struct {
+			user  string
+			score int
+		}{
+			{"player2", 40},
+			{"player3", 30},
+			{"player2", 20},
+			{"player3", 10},
+		}
+		for _, s := range submissions {
+			postResp := api.Post(
+				fmt.Sprintf("/leaderboard/%s/submission", id),
+				authHeader(users[s.user]),
+				map[string]any{
+					"link":  "www.youtube.com",
+					"score": s.score,
+				})
+			assert.Equal(t, 200, postResp.Code)
+		}
+
+		rankResp := api.Get(fmt.Sprintf("/leaderboard/%s/rank/%s", id, users["player3"]))
+		if assert.Equal(t, 200, rankResp.Code) {
+			var rank UserRank
+			json.Unmarshal(rankResp.Body.Bytes(), &rank)
+			assert.Equal(t, 2, rank.Rank)
+			assert.Equal(t, 4, rank.Total)
+			assert.Equal(t, 75.0, rank.Percentile)
+			assert.Equal(t, "30", rank.Best.Score.String())
+			assert.Equal(t, users["player3"], rank.User.ID)
+		}
+
+		missingResp := api.Get(fmt.Sprintf("/leaderboard/%s/rank/%s", id, users["Anonymous1"]))
+		assert.Equal(t, 404, missingResp.Code)
+
+		lResp, getResp := getLeaderboard(t, api, id)
+		if !assert.Equal(t, 200, getResp.Code) || !assert.Equal(t, 4, len(lResp.Scores)) {
+			return
+		}
+
+		aroundResp := api.Get(fmt.Sprintf("/leaderboard/%s/submission/%s/around?n=1", id, lResp.Scores[2].ID))
+		if assert.Equal(t, 200, aroundResp.Code) {
+			var around LeaderboardResponseBody
+			json.Unmarshal(aroundResp.Body.Bytes(), &around)
+			scores := []string{}
+			for _, s := range around.Scores {
+				scores = append(scores, s.Score.String())
+			}
+			assert.Equal(t, []string{"30", "20", "10"}, scores)
+		}
+
+		topResp := api.Get(fmt.Sprintf("/leaderboard/%s/submission/%s/around?n=2", id, lResp.Scores[0].ID))
+		if assert.Equal(t, 200, topResp.Code) {
+			var around LeaderboardResponseBody
+			json.Unmarshal(topResp.Body.Bytes(), &around)
+			if assert.Equal(t, 3, len(around.Scores)) {
+				assert.Equal(t, 1, around.Scores[0].Rank)
+			}
+		}
+
+		unknownResp := api.Get(fmt.Sprintf("/leaderboard/%s/submission/%s/around", id, uuid.Must(uuid.NewV4())))
+		assert.Equal(t, 404, unknownResp.Code)
+	})
+}
+
+func TestUserRankDenseTies(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+		updateResp := api.Patch(fmt.Sprintf("/leaderboard/%s", id), authHeader(users["player2"]), map[string]any{"tie_policy": "dense"})
+		assert.Equal(t, 200, updateResp.Code)
+
+		for user, score := range map[string]int{"admin": 30, "player2": 30, "player3": 20} {
+			postResp := api.Post(
+				fmt.Sprintf("/leaderboard/%s/submission", id),
+				authHeader(users[user]),
+				map[string]any{
+					"link":  "www.youtube.com",
+					"score": score,
+				})
+			assert.Equal(t, 200, postResp.Code)
+		}
+
+		for user, expected := range map[string]struct {
+			rank       int
+			percentile float64
+		}{
+			"admin":   {1, 100},
+			"player2": {1, 100},
+			"player3": {2, 100.0 / 3},
+		} {
+			rankResp := api.Get(fmt.Sprintf("/leaderboard/%s/rank/%s", id, users[user]))
+			if assert.Equal(t, 200, rankResp.Code, user) {
+				var rank UserRank
+				json.Unmarshal(rankResp.Body.Bytes(), &rank)
+				assert.Equal(t, expected.rank, rank.Rank, user)
+				assert.Equal(t, 3, rank.Total, user)
+				assert.InDelta(t, expected.percentile, rank.Percentile, 0.001, user)
+			}
+		}
+	})
+}
+
+func TestRecurringLeaderboard(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		resp := api.Post("/leaderboard",
+			authHeader(users["player2"]),
+			map[string]any{
+				"title":         "Daily Challenge",
+				"highest_first": true,
+				"recurrence":    "daily",
+				"timezone":      "America/New_York",
+				"start":         time.Now().Format(time.RFC3339),
+			})
+		if !assert.Equal(t, 200, resp.Code) {
+			return
+		}
+		var created NewLeaderboardResponseBody
+		json.Unmarshal(resp.Body.Bytes(), &created)
+		id := created.Id
+
+		if info, infoResp := getLeaderboardInfo(t, api, id); assert.Equal(t, 200, infoResp.Code) {
+			assert.Equal(t, "daily", info.Recurrence)
+			assert.Equal(t, "America/New_York", info.Timezone)
+			assert.Equal(t, 1, info.CurrentPeriod.Number)
+			if assert.NotNil(t, info.CurrentPeriod.Stop) {
+				ny, _ := time.LoadLocation("America/New_York")
+				stop := info.CurrentPeriod.Stop.In(ny)
+				assert.Equal(t, 0, stop.Hour())
+				assert.True(t, stop.After(time.Now()))
+				assert.True(t, stop.Before(time.Now().Add(25*time.Hour)))
+			}
+		}
+
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 9,
+			})
+		assert.Equal(t, 200, postResp.Code)
+
+		periodResp := api.Get(fmt.Sprintf("/leaderboard/%s/period/1", id))
+		if assert.Equal(t, 200, periodResp.Code) {
+			var period LeaderboardPeriodResponseBody
+			json.Unmarshal(periodResp.Body.Bytes(), &period)
+			assert.Equal(t, 1, period.Period.Number)
+			assert.Equal(t, 1, len(period.Scores))
+		}
+
+		missingResp := api.Get(fmt.Sprintf("/leaderboard/%s/period/2", id))
+		assert.Equal(t, 404, missingResp.Code)
+
+		for _, bad := range []map[string]any{
+			{"recurrence": "fortnightly"},
+			{"recurrence": "0 0 30 2 *"},
+			{"recurrence": "daily", "timezone": "Mars/Olympus_Mons"},
+			{"timezone": "Mars/Olympus_Mons"},
+		} {
+			body := map[string]any{
+				"title":         "Bad Recurrence",
+				"highest_first": true,
+				"start":         time.Now().Format(time.RFC3339),
+			}
+			for k, v := range bad {
+				body[k] = v
+			}
+			badResp := api.Post("/leaderboard", authHeader(users["player2"]), body)
+			assert.Equal(t, 422, badResp.Code, bad)
+		}
+
+		for _, change := range []map[string]any{{"recurrence": "weekly"}, {"timezone": "Europe/Paris"}} {
+			changeResp := api.Patch(fmt.Sprintf("/leaderboard/%s", id), authHeader(users["player2"]), change)
+			assert.Equal(t, 422, changeResp.Code, change)
+		}
+	})
+}
+
+// readStreamEvent returns the name and data of the next server-sent event.
+func readStreamEvent(t *testing.T, stream *bufio.Scanner) (string, string) {
+	t.Helper()
+	var event, data string
+	for stream.Scan() {
+		line := stream.Text()
+		if len(line) == 0 && len(event) > 0 {
+			return event, data
+		}
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+		}
+		if d, ok := strings.CutPrefix(line, "data: "); ok {
+			data = d
+		}
+	}
+	t.Fatalf("stream ended: %v", stream.Err())
+	return "", ""
+}
+
+func TestLeaderboardStream(t *testing.T) {
+	WithAppState(t, func(ctx context.Context, app *App, api humatest.TestAPI, users map[string]string) {
+		id := createDefaultLeaderboard(t, api, users["player2"])
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 12,
+			})
+		if !assert.Equal(t, 200, postResp.Code) {
+			return
+		}
+		var submission SubmissionResponseBody
+		json.Unmarshal(postResp.Body.Bytes(), &submission)
+
+		missingResp := api.Get(fmt.Sprintf("/leaderboard/%s/stream", uuid.Must(uuid.NewV4())))
+		assert.Equal(t, 404, missingResp.Code)
+
+		server := httptest.NewServer(api.Adapter())
+		defer server.Close()
+		resp, err := http.Get(fmt.Sprintf("%s/leaderboard/%s/stream", server.URL, id))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		stream := bufio.NewScanner(resp.Body)
+
+		event, data := readStreamEvent(t, stream)
+		assert.Equal(t, "rankings", event)
+		var rankings LeaderboardResponseBody
+		json.Unmarshal([]byte(data), &rankings)
+		assert.Equal(t, 1, len(rankings.Scores))
+		// The broker and notifications use UUIDs.
+		var leaderboard_uuid, submission_uuid uuid.UUID
+		leaderboard_seq, submission_seq := int64(id), int64(submission.ID)
+		assert.NoError(t, app.st.resolveID(ctx, "leaderboards", &leaderboard_uuid, &leaderboard_seq))
+		assert.NoError(t, app.st.resolveID(ctx, "submissions", &submission_uuid, &submission_seq))
+		assert.Equal(t, 1, app.events.subscriberCount(leaderboard_uuid))
+
+		// Notifications aren't delivered inside the test transaction, so
+		// publish the event the trigger would have sent.
+		app.events.Publish(LeaderboardEvent{Leaderboard: leaderboard_uuid, Submission: submission_uuid, Event: "verification"})
+
+		event, data = readStreamEvent(t, stream)
+		assert.Equal(t, "submission", event)
+		var changed SubmissionEvent
+		json.Unmarshal([]byte(data), &changed)
+		assert.Equal(t, "verification", changed.Type)
+		assert.Equal(t, submission.ID, changed.SubmissionID)
+		if assert.NotNil(t, changed.Ranking) {
+			assert.Equal(t, 1, changed.Ranking.Rank)
+			assert.Equal(t, "12", changed.Ranking.Score.String())
+		}
+
+		event, _ = readStreamEvent(t, stream)
+		assert.Equal(t, "rankings", event)
+	})
+}
+
+func TestShortIDs(t *testing.T) {
+	WithAppState(t, func(ctx context.Context, app *App, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player1"])
+		assert.Len(t, id.String(), id_length)
+
+		postResp := api.Post(fmt.Sprintf("/leaderboard/%s/submission", id), authHeader(users["player1"]), map[string]any{
+			"link":  "www.youtube.com",
+			"score": 12,
+		})
+		if !assert.Equal(t, 200, postResp.Code) {
+			return
+		}
+		var submission SubmissionResponseBody
+		json.Unmarshal(postResp.Body.Bytes(), &submission)
+
+		var leaderboard_uuid, submission_uuid uuid.UUID
+		leaderboard_seq, submission_seq := int64(id), int64(submission.ID)
+		assert.NoError(t, app.st.resolveID(ctx, "leaderboards", &leaderboard_uuid, &leaderboard_seq))
+		assert.NoError(t, app.st.resolveID(ctx, "submissions", &submission_uuid, &submission_seq))
+
+		// UUIDs are still accepted, and short IDs are returned either way.
+		for _, path_id := range []string{id.String(), leaderboard_uuid.String()} {
+			infoResp := api.Get(fmt.Sprintf("/leaderboard/%s/info", path_id))
+			if assert.Equal(t, 200, infoResp.Code) {
+				var info map[string]any
+				json.Unmarshal(infoResp.Body.Bytes(), &info)
+				assert.Equal(t, id.String(), info["id"])
+			}
+		}
+		for _, path_id := range []string{submission.ID.String(), submission_uuid.String()} {
+			getResp := api.Get(fmt.Sprintf("/leaderboard/%s/submission/%s", leaderboard_uuid, path_id))
+			if assert.Equal(t, 200, getResp.Code, path_id) {
+				var submitInfo DetailedSubmission
+				json.Unmarshal(getResp.Body.Bytes(), &submitInfo)
+				assert.Equal(t, submission.ID, submitInfo.ID)
+				assert.Equal(t, id, submitInfo.LeaderboardID)
+			}
+		}
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) && assert.Equal(t, 1, len(lResp.Scores)) {
+			assert.Equal(t, submission.ID, lResp.Scores[0].ID)
+		}
+
+		assert.Equal(t, 422, api.Get("/leaderboard/not_an_id/info").Code)
+		assert.Equal(t, 404, api.Get(fmt.Sprintf("/leaderboard/%s/info", LeaderboardShortID(1<<40))).Code)
+	})
+}
+
+func TestLeaderboardSlugs(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["admin"])
+		other := createBasicLeaderboard(t, api, users["player2"])
+		setSlug := func(leaderboard LeaderboardShortID, user string, slug string) *httptest.ResponseRecorder {
+			return api.Put(fmt.Sprintf("/leaderboard/%s/slug", leaderboard), authHeader(users[user]), map[string]any{"slug": slug})
+		}
+
+		assert.Equal(t, 403, setSlug(id, "player2", "speedrun-any-percent").Code)
+		resp := setSlug(id, "admin", "Speedrun-Any-Percent")
+		if !assert.Equal(t, 200, resp.Code) {
+			return
+		}
+		var info map[string]any
+		json.Unmarshal(resp.Body.Bytes(), &info)
+		assert.Equal(t, "Speedrun-Any-Percent", info["slug"])
+
+		// Slugs work in place of the ID, whatever their case.
+		infoResp := api.Get("/leaderboard/speedrun-any-percent/info")
+		if assert.Equal(t, 200, infoResp.Code) {
+			json.Unmarshal(infoResp.Body.Bytes(), &info)
+			assert.Equal(t, id.String(), info["id"])
+		}
+		assert.Equal(t, 200, api.Get("/leaderboard/speedrun-any-percent").Code)
+
+		taken := setSlug(other, "player2", "SPEEDRUN-any-percent")
+		assert.Equal(t, 409, taken.Code)
+		assert.Contains(t, taken.Body.String(), error_code_already_exists)
+
+		for _, invalid := range []string{"admin", "bad--slug", "no", id.String(), uuid.Must(uuid.NewV4()).String()} {
+			assert.Equal(t, 422, setSlug(other, "player2", invalid).Code, invalid)
+		}
+
+		// Old slugs keep resolving after a rename, and stay with the leaderboard.
+		assert.Equal(t, 200, setSlug(id, "admin", "any-percent").Code)
+		assert.Equal(t, 200, api.Get("/leaderboard/speedrun-any-percent/info").Code)
+		assert.Equal(t, 409, setSlug(other, "player2", "speedrun-any-percent").Code)
+		for _, slug := range []string{"any-percent", "speedrun-any-percent"} {
+			redirect := api.Get("/l/" + slug)
+			assert.Equal(t, 308, redirect.Code)
+			assert.Equal(t, "/leaderboard/"+id.String(), redirect.Header().Get("Location"))
+		}
+		assert.Equal(t, 404, api.Get("/l/no-such-slug").Code)
+		assert.Equal(t, 404, api.Get("/leaderboard/no-such-slug/info").Code)
+
+		assert.Equal(t, 200, setSlug(id, "admin", "speedrun-any-percent").Code)
+	})
+}
+
+func TestLeaderboardVisibility(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		resp := api.Post("/leaderboard", authHeader(users["admin"]), map[string]any{
+			"title":         "Playtest",
+			"highest_first": true,
+			"start":         time.Now().Format(time.RFC3339),
+			"visibility":    "private",
+		})
+		if !assert.Equal(t, 200, resp.Code) {
+			return
+		}
+		var created NewLeaderboardResponseBody
+		json.Unmarshal(resp.Body.Bytes(), &created)
+		id := created.Id
+		info := fmt.Sprintf("/leaderboard/%s/info", id)
+		submission := fmt.Sprintf("/leaderboard/%s/submission", id)
+		members := fmt.Sprintf("/leaderboard/%s/members", id)
+		accountLeaderboards := fmt.Sprintf("/account/%s/leaderboards", users["admin"])
+		score := map[string]any{"link": "www.youtube.com", "score": 10}
+
+		// Private leaderboards are hidden from everyone else.
+		assert.Equal(t, 404, api.Get(info).Code)
+		assert.Equal(t, 404, api.Get(fmt.Sprintf("/leaderboard/%s", id)).Code)
+		assert.Equal(t, 404, api.Get(info, authHeader(users["player2"])).Code)
+		assert.Equal(t, 404, api.Post(submission, authHeader(users["player2"]), score).Code)
+		assert.NotContains(t, api.Get(accountLeaderboards).Body.String(), id.String())
+		assert.Contains(t, api.Get(accountLeaderboards, authHeader(users["admin"])).Body.String(), id.String())
+		ownerResp := api.Get(info, authHeader(users["admin"]))
+		if assert.Equal(t, 200, ownerResp.Code) {
+			assert.Contains(t, ownerResp.Body.String(), `"visibility":"private"`)
+		}
+
+		// Members can read and submit.
+		assert.Equal(t, 403, api.Post(members, authHeader(users["player2"]), map[string]any{"user_id": users["player2"]}).Code)
+		addResp := api.Post(members, authHeader(users["admin"]), map[string]any{"user_id": users["player2"]})
+		if assert.Equal(t, 200, addResp.Code) {
+			assert.Contains(t, addResp.Body.String(), users["player2"])
+		}
+		assert.Equal(t, 409, api.Post(members, authHeader(users["admin"]), map[string]any{"username": "player2"}).Code)
+		assert.Equal(t, 200, api.Get(info, authHeader(users["player2"])).Code)
+		assert.Equal(t, 200, api.Post(submission, authHeader(users["player2"]), score).Code)
+
+		// API keys need the read scope to read.
+		readKey := createAPIKey(t, api, id, users["admin"], "read")
+		submitKey := createAPIKey(t, api, id, users["admin"], "submit")
+		assert.Equal(t, 200, api.Get(info, "Authorization: Bearer "+readKey.Key).Code)
+		assert.Equal(t, 403, api.Get(info, "Authorization: Bearer "+submitKey.Key).Code)
+
+		assert.Equal(t, 200, api.Delete(fmt.Sprintf("%s/%s", members, users["player2"]), authHeader(users["admin"])).Code)
+		assert.Equal(t, 404, api.Get(info, authHeader(users["player2"])).Code)
+
+		// Unlisted leaderboards can be read by anyone with the UUID or a slug,
+		// but aren't listed.
+		assert.Equal(t, 200, api.Patch(fmt.Sprintf("/leaderboard/%s", id), authHeader(users["admin"]), map[string]any{"visibility": "unlisted"}).Code)
+		assert.Equal(t, 200, api.Get(fmt.Sprintf("/leaderboard/%s/info", created.UUID)).Code)
+		assert.Equal(t, 200, api.Post(fmt.Sprintf("/leaderboard/%s/submission", created.UUID), authHeader(users["player2"]), score).Code)
+		assert.Equal(t, 200, api.Put(fmt.Sprintf("/leaderboard/%s/slug", id), authHeader(users["admin"]), map[string]any{"slug": "unlisted-playtest"}).Code)
+		assert.Equal(t, 200, api.Get("/leaderboard/unlisted-playtest/info").Code)
+		redirect := api.Get("/l/unlisted-playtest")
+		if assert.Equal(t, 308, redirect.Code) {
+			assert.Equal(t, "/leaderboard/unlisted-playtest", redirect.Header().Get("Location"))
+		}
+
+		// Short IDs are sequential, so they don't open unlisted leaderboards
+		// for anyone but their creator, verifiers and members.
+		for seq := max(int64(id)-5, 1); seq <= int64(id)+5; seq++ {
+			enumerated := api.Get(fmt.Sprintf("/leaderboard/%s/info", LeaderboardShortID(seq)))
+			assert.NotContains(t, enumerated.Body.String(), created.UUID.String())
+		}
+		assert.Equal(t, 404, api.Get(info).Code)
+		assert.Equal(t, 404, api.Get(fmt.Sprintf("/leaderboard/%s", id), authHeader(users["player2"])).Code)
+		assert.Equal(t, 200, api.Get(info, authHeader(users["admin"])).Code)
+		assert.NotContains(t, api.Get(accountLeaderboards).Body.String(), id.String())
+		submissions := api.Get(fmt.Sprintf("/account/%s/submissions", users["player2"]))
+		assert.NotContains(t, submissions.Body.String(), id.String())
+	})
+}
+
+func TestPrivateSubmissionThroughPublicLeaderboard(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		resp := api.Post("/leaderboard", authHeader(users["admin"]), map[string]any{
+			"title":         "Playtest",
+			"highest_first": true,
+			"start":         time.Now().Format(time.RFC3339),
+			"visibility":    "private",
+		})
+		if !assert.Equal(t, 200, resp.Code) {
+			return
+		}
+		var created NewLeaderboardResponseBody
+		json.Unmarshal(resp.Body.Bytes(), &created)
+		submitResp := api.Post(fmt.Sprintf("/leaderboard/%s/submission", created.Id), authHeader(users["admin"]), map[string]any{"link": "www.youtube.com", "score": 10})
+		if !assert.Equal(t, 200, submitResp.Code) {
+			return
+		}
+		var private SubmissionResponseBody
+		json.Unmarshal(submitResp.Body.Bytes(), &private)
+		privateSubmission := fmt.Sprintf("/leaderboard/%s/submission/%s", created.Id, private.ID)
+		assert.Equal(t, 200, api.Post(privateSubmission+"/comment", authHeader(users["admin"]), map[string]any{"comment": "secret"}).Code)
+
+		// The submission can't be read or changed through a leaderboard it isn't on.
+		public := createBasicLeaderboard(t, api, users["player2"])
+		publicSubmission := fmt.Sprintf("/leaderboard/%s/submission/%s", public, private.ID)
+		historyResp := api.Get(publicSubmission+"/history", authHeader(users["player2"]))
+		assert.Equal(t, 404, historyResp.Code)
+		assert.NotContains(t, historyResp.Body.String(), "secret")
+		assert.Equal(t, 404, api.Post(publicSubmission+"/comment", authHeader(users["player2"]), map[string]any{"comment": "found you"}).Code)
+		assert.Equal(t, 404, api.Patch(publicSubmission+"/verify", authHeader(users["player2"]), map[string]any{"is_valid": false, "comment": "found you"}).Code)
+
+		ownerResp := api.Get(privateSubmission+"/history", authHeader(users["admin"]))
+		if assert.Equal(t, 200, ownerResp.Code) {
+			var history HistoryResponseBody
+			json.Unmarshal(ownerResp.Body.Bytes(), &history)
+			assert.Equal(t, 1, len(history.History))
+			assert.NotContains(t, ownerResp.Body.String(), "found you")
+		}
+	})
+}
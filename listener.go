@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Listener LISTENs on Postgres notification channels over a dedicated
+// connection and passes each payload to the channel's handler. Pooled
+// connections can't be used since LISTEN is bound to the session.
+type Listener struct {
+	connURL  string
+	handlers map[string]func(payload string)
+}
+
+func NewListener(connURL string) *Listener {
+	return &Listener{
+		connURL:  connURL,
+		handlers: map[string]func(payload string){},
+	}
+}
+
+// Handle registers f for notifications on channel. It must be called before Run.
+func (l *Listener) Handle(channel string, f func(payload string)) {
+	l.handlers[channel] = f
+}
+
+// Run listens until ctx is cancelled, reconnecting with backoff whenever the
+// connection is lost. Notifications sent while disconnected are missed.
+func (l *Listener) Run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := l.listen(ctx, func() { backoff = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Notification listener disconnected, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (l *Listener) listen(ctx context.Context, connected func()) error {
+	conn, err := pgx.Connect(ctx, l.connURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	for channel := range l.handlers {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+	}
+	connected()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if f, ok := l.handlers[n.Channel]; ok {
+			f(n.Payload)
+		}
+	}
+}
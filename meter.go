@@ -19,8 +19,8 @@ type CustomerInfo struct {
 const CUSTOMER_CONTEXT_KEY = "customer"
 
 func (app *App) CustomerMiddleware(ctx huma.Context, next func(huma.Context)) {
-	if user_id := ctx.Header("UserID"); len(user_id) > 0 {
-		customer, db_err := app.st.getCustomer(ctx.Context(), user_id)
+	if user, ok := ctx.Context().Value(USER_CONTEXT_KEY).(*User); ok {
+		customer, db_err := app.st.getCustomer(ctx.Context(), user.ID)
 		if db_err != nil && db_err != pgx.ErrNoRows {
 			huma.WriteErr(app.api, ctx, http.StatusInternalServerError,
 				"Could not find user.", db_err,
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+//go:embed migrations/*.sql
+var migration_files embed.FS
+
+// Arbitrary key for pg_advisory_lock, shared by every instance running migrations.
+const migration_lock_id = 7301944562
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// loadMigrations reads the embedded migrations/NNNN_name.{up,down}.sql files,
+// sorted by version. Every version must have both an up and a down file.
+func loadMigrations() ([]Migration, error) {
+	names, err := fs.Glob(migration_files, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	by_version := map[int64]*Migration{}
+	for _, name := range names {
+		base := path.Base(name)
+		stem, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.up.sql or NNNN_name.down.sql", base)
+		}
+		raw_version, title, _ := strings.Cut(stem, "_")
+		version, err := strconv.ParseInt(raw_version, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", base, err)
+		}
+		contents, err := migration_files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := by_version[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			by_version[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range by_version {
+		if len(m.Up) == 0 || len(m.Down) == 0 {
+			return nil, fmt.Errorf("migration %d_%s: missing up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// withMigrationLock holds a session level advisory lock for the duration of f
+// so concurrently starting instances apply migrations one at a time.
+func withMigrationLock(ctx context.Context, conn *pgx.Conn, f func() error) error {
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, int64(migration_lock_id)); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, int64(migration_lock_id))
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return err
+	}
+	return f()
+}
+
+func appliedMigrations(ctx context.Context, conn *pgx.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var applied_at time.Time
+		if err := rows.Scan(&version, &applied_at); err != nil {
+			return nil, err
+		}
+		applied[version] = applied_at
+	}
+	return applied, rows.Err()
+}
+
+// migrateUp applies every pending migration in order, each in its own transaction.
+func migrateUp(ctx context.Context, conn *pgx.Conn) ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	ran := []Migration{}
+	err = withMigrationLock(ctx, conn, func() error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `INSERT INTO schema_migrations(version, name) VALUES ($1, $2)`, m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			ran = append(ran, m)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// migrateDown reverts the most recently applied migrations, up to steps of them.
+func migrateDown(ctx context.Context, conn *pgx.Conn, steps int) ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []Migration{}
+	err = withMigrationLock(ctx, conn, func() error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version=$1`, m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+func migrationStatus(ctx context.Context, conn *pgx.Conn) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	err = withMigrationLock(ctx, conn, func() error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			status := MigrationStatus{Migration: m}
+			if applied_at, ok := applied[m.Version]; ok {
+				status.AppliedAt = &applied_at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// runMigrations brings the database at connURL up to the latest schema.
+func runMigrations(ctx context.Context, connURL string) error {
+	conn, err := pgx.Connect(ctx, connURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	ran, err := migrateUp(ctx, conn)
+	for _, m := range ran {
+		log.Printf("Applied migration %d_%s", m.Version, m.Name)
+	}
+	return err
+}
+
+func migrateCommand(connURL string) *cobra.Command {
+	connect := func(cmd *cobra.Command) *pgx.Conn {
+		conn, err := pgx.Connect(cmd.Context(), connURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return conn
+	}
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database schema migrations",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			conn := connect(cmd)
+			defer conn.Close(context.Background())
+
+			ran, err := migrateUp(cmd.Context(), conn)
+			for _, m := range ran {
+				fmt.Printf("Applied %d_%s\n", m.Version, m.Name)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		}})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "down [steps]",
+		Short: "Revert the most recent migrations (default 1)",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			steps := 1
+			if len(args) == 1 {
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n < 1 {
+					log.Fatalf("steps must be a positive integer, got %q", args[0])
+				}
+				steps = n
+			}
+			conn := connect(cmd)
+			defer conn.Close(context.Background())
+
+			reverted, err := migrateDown(cmd.Context(), conn, steps)
+			for _, m := range reverted {
+				fmt.Printf("Reverted %d_%s\n", m.Version, m.Name)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		}})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "List migrations and whether they have been applied",
+		Run: func(cmd *cobra.Command, args []string) {
+			conn := connect(cmd)
+			defer conn.Close(context.Background())
+
+			statuses, err := migrationStatus(cmd.Context(), conn)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, s := range statuses {
+				applied := "pending"
+				if s.AppliedAt != nil {
+					applied = s.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, applied)
+			}
+		}})
+	return cmd
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	assert.NoError(t, err)
+
+	if assert.NotEmpty(t, migrations) {
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "init", migrations[0].Name)
+	}
+	for i, m := range migrations {
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+		if i > 0 {
+			assert.Less(t, migrations[i-1].Version, m.Version)
+		}
+	}
+}
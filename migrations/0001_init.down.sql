@@ -0,0 +1,8 @@
+-- The "user", "session", "account" and "verification" tables are owned by the
+-- auth service and are left in place.
+DROP TRIGGER IF EXISTS trig_update_time ON submissions;
+DROP FUNCTION IF EXISTS function_update_timestamp();
+
+DROP TABLE IF EXISTS verifiers, submission_updates, submissions, leaderboards, customers;
+
+DROP TYPE IF EXISTS submission_action;
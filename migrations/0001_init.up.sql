@@ -35,7 +35,11 @@ CREATE TABLE IF NOT EXISTS leaderboards (
 	PRIMARY KEY(id, created_by)
 );
 
-ALTER TABLE leaderboards ADD CONSTRAINT start_before_stop CHECK (start < stop OR stop IS NULL);
+DO $$ BEGIN
+	ALTER TABLE leaderboards ADD CONSTRAINT start_before_stop CHECK (start < stop OR stop IS NULL);
+EXCEPTION
+	WHEN duplicate_object THEN null;
+END $$;
 
 
 
@@ -0,0 +1,15 @@
+DELETE FROM submission_updates WHERE action IN ('edit', 'withdraw');
+
+ALTER TABLE submission_updates
+	DROP COLUMN IF EXISTS previous_score,
+	DROP COLUMN IF EXISTS score,
+	DROP COLUMN IF EXISTS previous_link,
+	DROP COLUMN IF EXISTS link;
+
+ALTER TABLE submissions DROP COLUMN IF EXISTS withdrawn_at;
+
+-- Enum values can't be dropped, so rebuild the type without them.
+ALTER TYPE submission_action RENAME TO submission_action_old;
+CREATE TYPE submission_action AS ENUM ('validate', 'invalidate', 'comment');
+ALTER TABLE submission_updates ALTER COLUMN action TYPE submission_action USING action::text::submission_action;
+DROP TYPE submission_action_old;
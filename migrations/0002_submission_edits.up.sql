@@ -0,0 +1,10 @@
+ALTER TYPE submission_action ADD VALUE IF NOT EXISTS 'edit';
+ALTER TYPE submission_action ADD VALUE IF NOT EXISTS 'withdraw';
+
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS withdrawn_at TIMESTAMP;
+
+ALTER TABLE submission_updates
+	ADD COLUMN IF NOT EXISTS previous_score NUMERIC,
+	ADD COLUMN IF NOT EXISTS score NUMERIC,
+	ADD COLUMN IF NOT EXISTS previous_link TEXT,
+	ADD COLUMN IF NOT EXISTS link TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE leaderboards
+	DROP COLUMN IF EXISTS archived_at,
+	DROP COLUMN IF EXISTS deleted_at;
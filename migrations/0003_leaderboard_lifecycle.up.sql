@@ -0,0 +1,3 @@
+ALTER TABLE leaderboards
+	ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP,
+	ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
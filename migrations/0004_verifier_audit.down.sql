@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS verifier_updates;
+DROP TYPE IF EXISTS verifier_action;
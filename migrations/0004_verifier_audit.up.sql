@@ -0,0 +1,22 @@
+DO $$ BEGIN
+	CREATE TYPE verifier_action AS ENUM ('add', 'remove');
+EXCEPTION
+	WHEN duplicate_object THEN null;
+END $$;
+
+CREATE TABLE IF NOT EXISTS verifier_updates (
+	id UUID NOT NULL DEFAULT gen_random_uuid() PRIMARY KEY,
+	leaderboard UUID REFERENCES leaderboards(id),
+	userid TEXT REFERENCES "user"(id) ON UPDATE CASCADE,
+	author TEXT REFERENCES "user"(id) ON UPDATE CASCADE,
+	action verifier_action NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS verifier_updates_leaderboard ON verifier_updates(leaderboard, created_at);
+
+-- Creators were added as verifiers before changes were recorded.
+INSERT INTO verifier_updates(leaderboard, userid, author, action, created_at)
+SELECT verifiers.leaderboard, verifiers.userid, verifiers.userid, 'add', verifiers.added_at
+FROM verifiers
+WHERE NOT EXISTS(SELECT 1 FROM verifier_updates WHERE verifier_updates.leaderboard=verifiers.leaderboard);
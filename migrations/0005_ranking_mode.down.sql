@@ -0,0 +1 @@
+ALTER TABLE leaderboards DROP COLUMN IF EXISTS ranking_mode;
@@ -0,0 +1,3 @@
+ALTER TABLE leaderboards
+	ADD COLUMN IF NOT EXISTS ranking_mode TEXT NOT NULL DEFAULT 'all'
+	CONSTRAINT valid_ranking_mode CHECK (ranking_mode IN ('all', 'best', 'latest'));
@@ -0,0 +1,3 @@
+ALTER TABLE leaderboards
+	ADD COLUMN IF NOT EXISTS tie_policy TEXT NOT NULL DEFAULT 'standard'
+	CONSTRAINT valid_tie_policy CHECK (tie_policy IN ('standard', 'dense', 'earliest'));
@@ -0,0 +1,3 @@
+ALTER TABLE leaderboards
+	ADD COLUMN IF NOT EXISTS score_precision SMALLINT NOT NULL DEFAULT 0
+	CONSTRAINT valid_score_precision CHECK (score_precision BETWEEN 0 AND 9);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS leaderboard_periods;
+
+ALTER TABLE leaderboards
+	DROP COLUMN IF EXISTS recurrence,
+	DROP COLUMN IF EXISTS timezone;
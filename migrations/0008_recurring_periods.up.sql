@@ -0,0 +1,18 @@
+ALTER TABLE leaderboards
+	ADD COLUMN IF NOT EXISTS recurrence TEXT,
+	ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
+
+-- Every leaderboard has at least period 1. A NULL start means the period
+-- begins with the leaderboard, and the latest period is treated as open ended
+-- until the scheduler starts the next one.
+CREATE TABLE IF NOT EXISTS leaderboard_periods (
+	leaderboard UUID REFERENCES leaderboards(id) ON DELETE CASCADE,
+	number INT NOT NULL,
+	start TIMESTAMP,
+	stop TIMESTAMP,
+	PRIMARY KEY (leaderboard, number)
+);
+
+INSERT INTO leaderboard_periods(leaderboard, number)
+SELECT id, 1 FROM leaderboards
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,8 @@
+CREATE OR REPLACE FUNCTION function_update_timestamp() RETURNS TRIGGER AS
+$BODY$
+BEGIN
+	UPDATE leaderboards SET last_updated=NOW() WHERE NEW.leaderboard=leaderboards.id;
+        RETURN NEW;
+END;
+$BODY$
+language plpgsql;
@@ -0,0 +1,20 @@
+-- Also publish each submission change on the leaderboard_events channel so
+-- every server instance can push it to connected stream clients.
+CREATE OR REPLACE FUNCTION function_update_timestamp() RETURNS TRIGGER AS
+$BODY$
+BEGIN
+	UPDATE leaderboards SET last_updated=NOW() WHERE NEW.leaderboard=leaderboards.id;
+	PERFORM pg_notify('leaderboard_events', json_build_object(
+		'leaderboard', NEW.leaderboard,
+		'submission', NEW.id,
+		'event', CASE
+			WHEN TG_OP = 'INSERT' THEN 'submission'
+			WHEN NEW.withdrawn_at IS NOT NULL AND OLD.withdrawn_at IS NULL THEN 'withdrawal'
+			WHEN NEW.verified IS DISTINCT FROM OLD.verified THEN 'verification'
+			ELSE 'update'
+		END
+	)::text);
+        RETURN NEW;
+END;
+$BODY$
+language plpgsql;
@@ -0,0 +1,4 @@
+ALTER TABLE leaderboards DROP COLUMN IF EXISTS close_notified_at;
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
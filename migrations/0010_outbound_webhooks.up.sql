@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+	id UUID NOT NULL DEFAULT gen_random_uuid() PRIMARY KEY,
+	leaderboard UUID NOT NULL REFERENCES leaderboards(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhook_endpoints_leaderboard ON webhook_endpoints(leaderboard);
+
+-- A delivery is pending while next_attempt_at is set, and has failed for good
+-- once it is cleared without delivered_at being set.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id UUID NOT NULL DEFAULT gen_random_uuid() PRIMARY KEY,
+	endpoint UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+	event TEXT NOT NULL,
+	data JSONB NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP,
+	last_attempt_at TIMESTAMP,
+	last_status INT,
+	last_error TEXT,
+	delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE next_attempt_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS webhook_deliveries_endpoint ON webhook_deliveries(endpoint, created_at);
+
+-- Set once leaderboard.closed has been queued for a leaderboard. Leaderboards
+-- that closed before webhooks existed are skipped.
+ALTER TABLE leaderboards ADD COLUMN IF NOT EXISTS close_notified_at TIMESTAMP;
+
+UPDATE leaderboards SET close_notified_at=CURRENT_TIMESTAMP
+WHERE close_notified_at IS NULL AND (archived_at IS NOT NULL OR stop <= CURRENT_TIMESTAMP);
@@ -0,0 +1,15 @@
+-- Keys are only stored hashed. The prefix is kept so owners can tell keys apart.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id UUID NOT NULL DEFAULT gen_random_uuid() PRIMARY KEY,
+	leaderboard UUID NOT NULL REFERENCES leaderboards(id) ON DELETE CASCADE,
+	created_by TEXT NOT NULL REFERENCES "user"(id) ON UPDATE CASCADE,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT[] NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_leaderboard ON api_keys(leaderboard, created_at);
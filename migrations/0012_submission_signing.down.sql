@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS submission_nonces;
+
+ALTER TABLE leaderboards DROP COLUMN IF EXISTS submission_secret;
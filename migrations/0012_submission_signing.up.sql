@@ -0,0 +1,13 @@
+-- When set, new scores must be signed with this secret.
+ALTER TABLE leaderboards ADD COLUMN IF NOT EXISTS submission_secret TEXT;
+
+-- Nonces of signed submissions, kept for as long as their timestamps are
+-- accepted so each can only be used once.
+CREATE TABLE IF NOT EXISTS submission_nonces (
+	leaderboard UUID REFERENCES leaderboards(id) ON DELETE CASCADE,
+	nonce TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (leaderboard, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS submission_nonces_created_at ON submission_nonces(created_at);
@@ -0,0 +1,12 @@
+-- Responses to requests sent with an Idempotency-Key, replayed on retries.
+-- The response is NULL while the first request is still being handled.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	owner TEXT NOT NULL,
+	key TEXT NOT NULL,
+	request_hash TEXT NOT NULL,
+	response JSONB,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (owner, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at ON idempotency_keys(created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE submissions DROP COLUMN IF EXISTS seq;
+ALTER TABLE leaderboards DROP COLUMN IF EXISTS seq;
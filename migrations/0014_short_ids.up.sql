@@ -0,0 +1,7 @@
+-- Sequence numbers that short IDs are encoded from. Existing rows are
+-- numbered when the columns are added.
+ALTER TABLE leaderboards ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+CREATE UNIQUE INDEX IF NOT EXISTS leaderboards_seq ON leaderboards(seq);
+
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+CREATE UNIQUE INDEX IF NOT EXISTS submissions_seq ON submissions(seq);
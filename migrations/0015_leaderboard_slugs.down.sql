@@ -0,0 +1,2 @@
+ALTER TABLE leaderboards DROP COLUMN IF EXISTS slug;
+DROP TABLE IF EXISTS leaderboard_slugs;
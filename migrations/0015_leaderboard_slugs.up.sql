@@ -0,0 +1,12 @@
+-- Vanity slugs. Every slug a leaderboard has had is kept, lowercased, so
+-- links using an old slug still resolve and no other leaderboard can take it.
+CREATE TABLE IF NOT EXISTS leaderboard_slugs (
+	slug TEXT PRIMARY KEY,
+	leaderboard UUID NOT NULL REFERENCES leaderboards(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS leaderboard_slugs_leaderboard ON leaderboard_slugs(leaderboard);
+
+-- The current slug, as the owner typed it.
+ALTER TABLE leaderboards ADD COLUMN IF NOT EXISTS slug TEXT;
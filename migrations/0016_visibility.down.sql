@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS leaderboard_members;
+ALTER TABLE leaderboards DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,13 @@
+-- Who can see a leaderboard: anyone (public), anyone with the ID (unlisted),
+-- or only its creator, verifiers and members (private).
+ALTER TABLE leaderboards
+	ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public'
+	CONSTRAINT valid_visibility CHECK (visibility IN ('public', 'unlisted', 'private'));
+
+CREATE TABLE IF NOT EXISTS leaderboard_members (
+	leaderboard UUID NOT NULL REFERENCES leaderboards(id) ON DELETE CASCADE,
+	userid TEXT NOT NULL REFERENCES "user"(id) ON UPDATE CASCADE,
+	added_by TEXT NOT NULL,
+	added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (leaderboard, userid)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS leaderboard_join_requests;
+DROP TABLE IF EXISTS leaderboard_invites;
+ALTER TABLE leaderboards DROP COLUMN IF EXISTS entry;
@@ -0,0 +1,27 @@
+-- Who can submit: anyone (open), or only members, who join with an invite
+-- code (invite) or also by asking the creator (approval).
+ALTER TABLE leaderboards
+	ADD COLUMN IF NOT EXISTS entry TEXT NOT NULL DEFAULT 'open'
+	CONSTRAINT valid_entry CHECK (entry IN ('open', 'invite', 'approval'));
+
+CREATE TABLE IF NOT EXISTS leaderboard_invites (
+	id UUID NOT NULL DEFAULT gen_random_uuid() PRIMARY KEY,
+	leaderboard UUID NOT NULL REFERENCES leaderboards(id) ON DELETE CASCADE,
+	code TEXT NOT NULL UNIQUE,
+	created_by TEXT NOT NULL REFERENCES "user"(id) ON UPDATE CASCADE,
+	max_uses INTEGER CONSTRAINT valid_max_uses CHECK (max_uses > 0),
+	uses INTEGER NOT NULL DEFAULT 0,
+	expires_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS leaderboard_invites_leaderboard ON leaderboard_invites(leaderboard, created_at);
+
+CREATE TABLE IF NOT EXISTS leaderboard_join_requests (
+	leaderboard UUID NOT NULL REFERENCES leaderboards(id) ON DELETE CASCADE,
+	userid TEXT NOT NULL REFERENCES "user"(id) ON UPDATE CASCADE,
+	message TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (leaderboard, userid)
+);
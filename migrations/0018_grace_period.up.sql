@@ -0,0 +1,4 @@
+-- Seconds after stop that submissions are still accepted and ranked.
+ALTER TABLE leaderboards
+	ADD COLUMN IF NOT EXISTS grace_period INTEGER NOT NULL DEFAULT 0
+	CONSTRAINT valid_grace_period CHECK (grace_period >= 0);
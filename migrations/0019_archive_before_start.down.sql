@@ -0,0 +1,3 @@
+UPDATE leaderboards SET stop = start + INTERVAL '1 second' WHERE stop = start;
+ALTER TABLE leaderboards DROP CONSTRAINT IF EXISTS start_before_stop;
+ALTER TABLE leaderboards ADD CONSTRAINT start_before_stop CHECK (start < stop OR stop IS NULL);
@@ -0,0 +1,4 @@
+-- Archiving a leaderboard before it starts closes it at the same time, so its
+-- window can be empty.
+ALTER TABLE leaderboards DROP CONSTRAINT IF EXISTS start_before_stop;
+ALTER TABLE leaderboards ADD CONSTRAINT start_before_stop CHECK (start <= stop OR stop IS NULL);
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Events leaderboard owners can subscribe webhooks to.
+const (
+	webhook_submission_created   = "submission.created"
+	webhook_submission_verified  = "submission.verified"
+	webhook_submission_commented = "submission.commented"
+	webhook_leaderboard_closed   = "leaderboard.closed"
+)
+
+const (
+	max_webhook_endpoints = 10
+	// How often due deliveries are sent, and at most how many at a time.
+	webhook_interval   = 5 * time.Second
+	webhook_batch_size = 20
+	webhook_timeout    = 10 * time.Second
+	// A claimed delivery is retried after this long if its instance dies
+	// before recording the attempt. A batch is sent one delivery after another,
+	// so the lease outlasts a batch in which every delivery times out.
+	webhook_lease = webhook_batch_size*webhook_timeout + time.Minute
+	// Attempts are spaced 30s, 1m, 2m, ... apart, giving up after about an hour.
+	webhook_max_attempts = 8
+	webhook_retry_base   = 30 * time.Second
+)
+
+// WebhookPayload is the body POSTed to webhook URLs.
+type WebhookPayload struct {
+	ID          uuid.UUID          `json:"id" doc:"Delivery ID, the same for every attempt."`
+	Event       string             `json:"event"`
+	Leaderboard LeaderboardShortID `json:"leaderboard_id" example:"tYLfjGTh9"`
+	TimeCreated time.Time          `json:"created_at"`
+	Data        json.RawMessage    `json:"data"`
+}
+
+type SubmissionWebhookData struct {
+	SubmissionID SubmissionShortID `json:"submission_id" example:"A4xACrHGM"`
+	UserID       string            `json:"user_id" doc:"Submitter, verifier or commenter, depending on the event."`
+	Score        *Score            `json:"score,omitempty"`
+	Link         string            `json:"link,omitempty"`
+	IsValid      *bool             `json:"is_valid,omitempty"`
+	Comment      string            `json:"comment,omitempty"`
+}
+
+// publicAddress reports whether ip is outside the loopback, private,
+// link-local and unspecified ranges, so webhooks can't reach services on the
+// server's own network.
+func publicAddress(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified())
+}
+
+// newWebhookClient returns a client that only connects to addresses allowed
+// reports true for. The address is checked as it's dialed, so a host that
+// resolved to a public address when the webhook was added can't later
+// resolve to a private one.
+func newWebhookClient(allowed func(net.IP) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhook_timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowed(ip) {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed instead of the webhook's host.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhook_timeout,
+		Transport: transport,
+		// A redirect could lead away from the registered HTTPS URL.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// webhookSignature signs a body the same way X-Signature is checked on
+// incoming webhooks: a hex encoded HMAC-SHA256 keyed with the secret.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// queueWebhook queues the event for the leaderboard's webhooks. Failing to
+// queue it doesn't fail the change that caused it.
+func (app *App) queueWebhook(ctx context.Context, leaderboard uuid.UUID, event string, data any) {
+	if err := app.st.queueWebhook(ctx, leaderboard, event, data); err != nil {
+		log.Printf("Could not queue %s webhook for %s: %v", event, leaderboard, err)
+	}
+}
+
+// runWebhooks sends due webhook deliveries until ctx is cancelled.
+func (app *App) runWebhooks(ctx context.Context) {
+	ticker := time.NewTicker(webhook_interval)
+	defer ticker.Stop()
+	for {
+		for {
+			sent, err := app.deliverWebhooks(ctx, time.Now())
+			if err != nil {
+				log.Printf("Could not deliver webhooks: %v", err)
+			}
+			// Keep going while there's a backlog.
+			if sent < webhook_batch_size {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverWebhooks sends a batch of deliveries due at now, returning how many
+// were attempted.
+func (app *App) deliverWebhooks(ctx context.Context, now time.Time) (int, error) {
+	claimed, err := app.st.claimWebhooks(ctx, now, now.Add(webhook_lease), webhook_batch_size)
+	if err != nil {
+		return 0, err
+	}
+	for _, w := range claimed {
+		var delivered_at, next_attempt *time.Time
+		status, send_err := app.sendWebhook(ctx, w)
+		var attempt_err *string
+		if send_err == nil {
+			t := time.Now().UTC()
+			delivered_at = &t
+		} else {
+			msg := send_err.Error()
+			attempt_err = &msg
+			if w.Attempts < webhook_max_attempts {
+				t := time.Now().Add(webhook_retry_base << (w.Attempts - 1)).UTC()
+				next_attempt = &t
+			}
+		}
+		if err := app.st.recordWebhookAttempt(ctx, w.ID, status, attempt_err, delivered_at, next_attempt); err != nil {
+			return len(claimed), err
+		}
+	}
+	return len(claimed), nil
+}
+
+// sendWebhook POSTs a delivery, returning the response status if one was
+// received and an error unless it was a 2xx.
+func (app *App) sendWebhook(ctx context.Context, w queuedWebhook) (*int, error) {
+	body, err := json.Marshal(WebhookPayload{
+		ID:          w.ID,
+		Event:       w.Event,
+		Leaderboard: w.Leaderboard,
+		TimeCreated: w.TimeCreated,
+		Data:        w.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "topktoday-webhooks/"+VERSION)
+	req.Header.Set("X-Event-Name", w.Event)
+	req.Header.Set("X-Webhook-Delivery", w.ID.String())
+	req.Header.Set("X-Signature", webhookSignature(w.Secret, body))
+
+	resp, err := app.webhookClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &resp.StatusCode, fmt.Errorf("received status %d", resp.StatusCode)
+	}
+	return &resp.StatusCode, nil
+}
+
+// validWebhookURL checks raw is an https URL whose host only resolves to
+// addresses allowed reports true for.
+func validWebhookURL(ctx context.Context, raw string, allowed func(net.IP) bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || len(u.Hostname()) == 0 {
+		return errors.New("Webhook URL must be an absolute https URL.")
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return errors.New("Webhook URL's host could not be resolved.")
+	}
+	for _, addr := range addrs {
+		if !allowed(addr.IP) {
+			return errors.New("Webhook URL must not point to a loopback, private or link-local address.")
+		}
+	}
+	return nil
+}
+
+func (app *App) addWebhook(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	NewWebhookBody
+}) (*WebhookEndpointResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	if err := validWebhookURL(ctx, input.Body.URL, app.webhookAddrAllowed); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	count, db_err := app.st.countWebhookEndpoints(ctx, input.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if count >= max_webhook_endpoints {
+		return nil, huma.Error409Conflict(fmt.Sprintf("A leaderboard can have at most %d webhooks.", max_webhook_endpoints))
+	}
+
+	endpoint, db_err := app.st.newWebhookEndpoint(ctx, input.ID, input.Body.URL, newWebhookSecret(), input.Body.Events)
+	if db_err != nil {
+		return nil, db_err
+	}
+	return &WebhookEndpointResponse{Body: endpoint}, nil
+}
+
+func (app *App) getWebhooks(ctx context.Context, input *struct {
+	LeaderboardIDParam
+}) (*WebhookEndpointsResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	endpoints, db_err := app.st.getWebhookEndpoints(ctx, input.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	resp := &WebhookEndpointsResponse{}
+	resp.Body.Webhooks = endpoints
+	return resp, nil
+}
+
+func (app *App) removeWebhook(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	WebhookIDParam
+}) (*MessageResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	count, db_err := app.st.deleteWebhookEndpoint(ctx, input.ID, input.WebhookID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if count == 0 {
+		return nil, huma.Error404NotFound("Webhook not found.")
+	}
+	resp := &MessageResponse{}
+	resp.Body.Message = "Webhook removed."
+	return resp, nil
+}
+
+func (app *App) getWebhookDeliveries(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	WebhookIDParam
+	PageParams
+}) (*WebhookDeliveriesResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, db_err := app.st.getWebhookDeliveries(ctx, input.ID, input.WebhookID, after, input.Limit+1)
+	if db_err != nil {
+		return nil, db_err
+	}
+	deliveries, next := page(deliveries, input.Limit, func(d WebhookDelivery) Cursor {
+		return Cursor{Time: d.TimeCreated, ID: d.ID.String()}
+	})
+
+	resp := &WebhookDeliveriesResponse{
+		Link: nextLink(fmt.Sprintf("%s/webhooks/%s/deliveries", input.path(), input.WebhookID), input.Limit, next),
+	}
+	resp.Body.Deliveries = deliveries
+	return resp, nil
+}
+
+func (app *App) redeliverWebhook(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	WebhookIDParam
+	DeliveryIDParam
+}) (*WebhookDeliveryResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	delivery, db_err := app.st.redeliverWebhook(ctx, input.ID, input.WebhookID, input.DeliveryID)
+	if db_err == pgx.ErrNoRows {
+		// Either there's no such delivery or it's still pending.
+		_, db_err = app.st.getWebhookDelivery(ctx, input.ID, input.WebhookID, input.DeliveryID)
+		if db_err == pgx.ErrNoRows {
+			return nil, huma.Error404NotFound("Delivery not found.")
+		}
+		if db_err != nil {
+			return nil, db_err
+		}
+		return nil, huma.Error409Conflict("Delivery is already queued.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+	return &WebhookDeliveryResponse{Body: delivery}, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Cursor is the keyset position of the last entry on a page. It is handed to
+// clients as an opaque base64 token.
+type Cursor struct {
+	Score *Score    `json:"s,omitempty"`
+	Time  time.Time `json:"t"`
+	ID    string    `json:"i"`
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses a cursor token. An empty token means the first page.
+func decodeCursor(token string) (*Cursor, error) {
+	if len(token) == 0 {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, huma.Error400BadRequest("Invalid cursor.")
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || len(c.ID) == 0 {
+		return nil, huma.Error400BadRequest("Invalid cursor.")
+	}
+	return &c, nil
+}
+
+// keyset returns the cursor position as query arguments, all nil for the first page.
+func (c *Cursor) keyset() (*Score, *time.Time, *string) {
+	if c == nil {
+		return nil, nil, nil
+	}
+	return c.Score, &c.Time, &c.ID
+}
+
+// page trims entries queried with limit+1 rows down to limit, returning the
+// cursor of the last kept entry when another page follows.
+func page[T any](entries []T, limit int, cursor func(T) Cursor) ([]T, *Cursor) {
+	if len(entries) <= limit {
+		return entries, nil
+	}
+	entries = entries[:limit]
+	next := cursor(entries[limit-1])
+	return entries, &next
+}
+
+// nextLink formats a Link header pointing at the next page, or "" if there is none.
+func nextLink(path string, limit int, next *Cursor) string {
+	if next == nil {
+		return ""
+	}
+	query := url.Values{
+		"cursor": {next.Encode()},
+		"limit":  {strconv.Itoa(limit)},
+	}
+	return fmt.Sprintf(`<%s?%s>; rel="next"`, path, query.Encode())
+}
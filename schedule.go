@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// How often the scheduler checks for leaderboard periods that have ended.
+const schedule_interval = time.Minute
+
+// Recurrence is a leaderboard reset schedule evaluated in a timezone: daily at
+// midnight, weekly on Monday, monthly on the 1st, or a five-field cron
+// expression (minute hour day-of-month month day-of-week).
+type Recurrence struct {
+	rule string
+	loc  *time.Location
+	cron *cronSchedule
+}
+
+func parseRecurrence(rule string, timezone string) (*Recurrence, error) {
+	if len(timezone) == 0 {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	r := &Recurrence{rule: rule, loc: loc}
+	switch rule {
+	case "daily", "weekly", "monthly":
+	default:
+		if r.cron, err = parseCron(rule); err != nil {
+			return nil, err
+		}
+		if r.Next(time.Now()).IsZero() {
+			return nil, fmt.Errorf("cron expression %q never matches", rule)
+		}
+	}
+	return r, nil
+}
+
+// Next returns the first period boundary strictly after t, or the zero time if
+// a cron expression has no match within five years.
+func (r *Recurrence) Next(t time.Time) time.Time {
+	local := t.In(r.loc)
+	y, m, d := local.Date()
+	switch r.rule {
+	case "daily":
+		return time.Date(y, m, d+1, 0, 0, 0, 0, r.loc)
+	case "weekly":
+		days := (8 - int(local.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		return time.Date(y, m, d+days, 0, 0, 0, 0, r.loc)
+	case "monthly":
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, r.loc)
+	}
+	return r.cron.next(local)
+}
+
+type cronField uint64
+
+func (f cronField) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow cronField
+	// Like cron, when both day fields are restricted either one may match.
+	anyDom, anyDow bool
+}
+
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("recurrence %q must be daily, weekly, monthly or a five-field cron expression", expr)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	parsed := [5]cronField{}
+	for i, field := range fields {
+		f, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+		parsed[i] = f
+	}
+	// Both 0 and 7 mean Sunday.
+	if parsed[4].has(7) {
+		parsed[4] |= 1
+	}
+	return &cronSchedule{
+		minute: parsed[0],
+		hour:   parsed[1],
+		dom:    parsed[2],
+		month:  parsed[3],
+		dow:    parsed[4],
+		anyDom: fields[2] == "*",
+		anyDow: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField reads a comma separated list of *, n, n-m, */s or n-m/s.
+func parseCronField(field string, min int, max int) (cronField, error) {
+	var f cronField
+	for _, part := range strings.Split(field, ",") {
+		span, step_str, has_step := strings.Cut(part, "/")
+		step := 1
+		if has_step {
+			s, err := strconv.Atoi(step_str)
+			if err != nil || s < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = s
+		}
+		lo, hi := min, max
+		if span != "*" {
+			lo_str, hi_str, is_range := strings.Cut(span, "-")
+			var err error
+			if lo, err = strconv.Atoi(lo_str); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if is_range {
+				if hi, err = strconv.Atoi(hi_str); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if has_step {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	}
+	return dom || dow
+}
+
+func (c *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		var skip time.Time
+		switch {
+		case !c.month.has(int(t.Month())):
+			skip = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			skip = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !c.hour.has(t.Hour()):
+			skip = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !c.minute.has(t.Minute()):
+			skip = t.Add(time.Minute)
+		default:
+			return t
+		}
+		// The local time skipped to can fall in a daylight saving gap, which
+		// time.Date may resolve to an earlier instant.
+		if !skip.After(t) {
+			skip = t.Add(time.Minute)
+		}
+		t = skip
+	}
+	return time.Time{}
+}
+
+// runScheduler starts the next period of every recurring leaderboard whose
+// current period has ended, until ctx is cancelled. Every instance runs it;
+// leaderboards are claimed with SKIP LOCKED so each rollover happens once.
+func (app *App) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedule_interval)
+	defer ticker.Stop()
+	for {
+		rolled, err := app.st.rollPeriods(ctx, time.Now())
+		if err != nil {
+			log.Printf("Could not start new leaderboard periods: %v", err)
+		}
+		for _, id := range rolled {
+			app.cache.Remove(id)
+		}
+		if err := app.st.queueClosedLeaderboardWebhooks(ctx, time.Now()); err != nil {
+			log.Printf("Could not queue leaderboard.closed webhooks: %v", err)
+		}
+		// Older nonces are outside the skew window, so can't be replayed.
+		if err := app.st.deleteSubmissionNonces(ctx, time.Now().Add(-2*submission_signature_skew)); err != nil {
+			log.Printf("Could not delete expired submission nonces: %v", err)
+		}
+		if err := app.st.deleteIdempotencyKeys(ctx, time.Now().Add(-app.idempotencyWindow)); err != nil {
+			log.Printf("Could not delete expired idempotency keys: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
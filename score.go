@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Largest exponent accepted in scientific notation, well beyond any real score.
+const max_score_exponent = 1000
+
+// Score is an exact decimal score. It is stored as NUMERIC and read from and
+// written to JSON as a number literal, so values never pass through float64.
+// On time leaderboards a score may also be given as a time string, which is
+// stored as a number of seconds.
+type Score struct {
+	pgtype.Numeric
+	// IsTime is set when the score was parsed from a time string.
+	IsTime bool `json:"-"`
+}
+
+func NewScore(s string) (Score, error) {
+	var score Score
+	err := score.UnmarshalJSON([]byte(s))
+	return score, err
+}
+
+func (s Score) Schema(r huma.Registry) *huma.Schema {
+	return &huma.Schema{
+		Description: "Exact decimal score, limited to the leaderboard's precision. Time leaderboards also accept a time such as \"1:02:33.450\" or \"PT1H2M33.45S\", stored in seconds.",
+		OneOf: []*huma.Schema{
+			{Type: huma.TypeNumber},
+			{Type: huma.TypeString},
+		},
+		Examples: []any{12.5},
+	}
+}
+
+// normalized returns the score with trailing zeros removed from its
+// coefficient, so equal values have the same representation.
+func (s Score) normalized() pgtype.Numeric {
+	if !s.Valid || s.Int == nil || s.Int.Sign() == 0 {
+		return pgtype.Numeric{Int: big.NewInt(0), Valid: s.Valid}
+	}
+	n := pgtype.Numeric{Int: new(big.Int).Set(s.Int), Exp: s.Exp, Valid: true}
+	ten := big.NewInt(10)
+	q, m := new(big.Int), new(big.Int)
+	for {
+		q.QuoRem(n.Int, ten, m)
+		if m.Sign() != 0 {
+			break
+		}
+		n.Int.Set(q)
+		n.Exp++
+	}
+	// Write whole numbers without an exponent.
+	for ; n.Exp > 0; n.Exp-- {
+		n.Int.Mul(n.Int, ten)
+	}
+	return n
+}
+
+// Decimals returns the number of significant digits after the decimal point.
+func (s Score) Decimals() int {
+	if exp := s.normalized().Exp; exp < 0 {
+		return int(-exp)
+	}
+	return 0
+}
+
+func (s Score) String() string {
+	b, _ := s.MarshalJSON()
+	return string(b)
+}
+
+func (s Score) MarshalJSON() ([]byte, error) {
+	if !s.Valid {
+		return []byte("null"), nil
+	}
+	return s.normalized().MarshalJSON()
+}
+
+func (s *Score) UnmarshalJSON(src []byte) error {
+	if bytes.Equal(src, []byte("null")) {
+		return nil
+	}
+	if len(src) > 0 && src[0] == '"' {
+		var raw string
+		if err := json.Unmarshal(src, &raw); err != nil {
+			return err
+		}
+		return s.parseTime(raw)
+	}
+	// JSON encoders write very large and very small numbers with an
+	// exponent, which pgtype.Numeric doesn't parse exactly.
+	mantissa, exponent, scientific := strings.Cut(strings.ToLower(string(src)), "e")
+	var n pgtype.Numeric
+	if err := n.UnmarshalJSON([]byte(mantissa)); err != nil {
+		return errors.New("score must be a decimal number")
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return errors.New("score must be a finite decimal number")
+	}
+	if scientific {
+		exp, err := strconv.ParseInt(exponent, 10, 32)
+		if err != nil || exp > max_score_exponent || exp < -max_score_exponent {
+			return errors.New("score exponent is out of range")
+		}
+		n.Exp += int32(exp)
+	}
+	s.Numeric = n
+	return nil
+}
+
+// Matches ISO-8601 durations in days, hours, minutes and seconds. Years and
+// months are rejected since their length varies.
+var iso_duration = regexp.MustCompile(`^P(?:(\d{1,12})D)?(?:T(?:(\d{1,12})H)?(?:(\d{1,12})M)?(?:(\d{1,12})(?:[.,](\d{1,9}))?S)?)?$`)
+
+// Matches [[h:]m:]s[.fff] clock times.
+var clock_time = regexp.MustCompile(`^(?:(?:(\d{1,12}):)?(\d{1,12}):)?(\d{1,12})(?:\.(\d{1,9}))?$`)
+
+// parseTime reads a clock time like 1:02:33.450 or an ISO-8601 duration like
+// PT1H2M33.45S into a score in seconds.
+func (s *Score) parseTime(raw string) error {
+	var days, hours, minutes, seconds, fraction string
+	if m := iso_duration.FindStringSubmatch(raw); m != nil && raw != "P" && !strings.HasSuffix(raw, "T") {
+		days, hours, minutes, seconds, fraction = m[1], m[2], m[3], m[4], m[5]
+	} else if m := clock_time.FindStringSubmatch(raw); m != nil {
+		hours, minutes, seconds, fraction = m[1], m[2], m[3], m[4]
+		// Only the leading unit of a clock time may exceed 59.
+		if (len(m[2]) > 0 && atoi(m[3]) > 59) || (len(m[1]) > 0 && atoi(m[2]) > 59) {
+			return fmt.Errorf("invalid time %q: minutes and seconds must be below 60", raw)
+		}
+	} else {
+		return fmt.Errorf("invalid time %q: expected h:mm:ss.fff or an ISO-8601 duration such as PT1H2M33.45S", raw)
+	}
+
+	total := ((atoi(days)*24+atoi(hours))*60+atoi(minutes))*60 + atoi(seconds)
+	score, err := NewScore(fmt.Sprintf("%d.%s0", total, fraction))
+	if err != nil {
+		return err
+	}
+	score.IsTime = true
+	*s = score
+	return nil
+}
+
+// atoi parses a regexp matched, length limited digit string, treating "" as 0.
+func atoi(digits string) int64 {
+	n, _ := strconv.ParseInt(digits, 10, 64)
+	return n
+}
+
+// Format renders the score truncated to precision decimal places, as a clock
+// time when isTime is set.
+func (s Score) Format(isTime bool, precision int) string {
+	if !s.Valid {
+		return ""
+	}
+	n := s.normalized()
+	// Scale to an integer number of 10^-precision units.
+	units := new(big.Int).Set(n.Int)
+	if shift := int64(n.Exp) + int64(precision); shift >= 0 {
+		units.Mul(units, new(big.Int).Exp(big.NewInt(10), big.NewInt(shift), nil))
+	} else {
+		units.Quo(units, new(big.Int).Exp(big.NewInt(10), big.NewInt(-shift), nil))
+	}
+
+	sign := ""
+	if units.Sign() < 0 {
+		sign = "-"
+		units.Neg(units)
+	}
+	whole, frac := new(big.Int).QuoRem(units, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil), new(big.Int))
+	out := whole.String()
+	if isTime {
+		minutes, seconds := new(big.Int).QuoRem(whole, big.NewInt(60), new(big.Int))
+		hours, minutes := new(big.Int).QuoRem(minutes, big.NewInt(60), new(big.Int))
+		if hours.Sign() > 0 {
+			out = fmt.Sprintf("%s:%02d:%02d", hours, minutes.Int64(), seconds.Int64())
+		} else {
+			out = fmt.Sprintf("%d:%02d", minutes.Int64(), seconds.Int64())
+		}
+	}
+	if precision > 0 {
+		out += fmt.Sprintf(".%0*s", precision, frac.String())
+	}
+	return sign + out
+}
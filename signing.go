@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+)
+
+// How far a signed submission's timestamp may be from the server's clock.
+const submission_signature_skew = 5 * time.Minute
+
+// SubmissionSignatureParams sign a score on a leaderboard with a signing
+// secret. X-Submission-Signature is the hex encoded HMAC-SHA256, keyed with the
+// secret, of the short leaderboard ID, player ID, score, nonce and timestamp joined
+// by newlines. The score is written as a plain decimal without trailing zeros,
+// in seconds on time leaderboards.
+type SubmissionSignatureParams struct {
+	Signature string `header:"X-Submission-Signature" doc:"Signature of the score, required on leaderboards with a signing secret."`
+	Nonce     string `header:"X-Submission-Nonce" maxLength:"128" doc:"Unique value for each signed submission."`
+	Timestamp int64  `header:"X-Submission-Timestamp" doc:"Unix time in seconds when the submission was signed."`
+}
+
+func submissionSigningMessage(leaderboard LeaderboardShortID, user_id string, score Score, nonce string, timestamp int64) string {
+	return strings.Join([]string{
+		leaderboard.String(),
+		user_id,
+		score.String(),
+		nonce,
+		strconv.FormatInt(timestamp, 10),
+	}, "\n")
+}
+
+func signSubmission(secret string, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkSubmissionSignature requires a valid, fresh and unused signature for
+// the score if the leaderboard has a signing secret, and reports whether it
+// used up the nonce. Scores sent with an API key come from a trusted server
+// and needn't be signed.
+func (app *App) checkSubmissionSignature(ctx context.Context, leaderboard LeaderboardIDParam, user_id string, score Score, sig SubmissionSignatureParams) (bool, error) {
+	if key, ok := ctx.Value(API_KEY_CONTEXT_KEY).(*APIKey); ok && key != nil {
+		return false, nil
+	}
+	secret, db_err := app.st.getSubmissionSecret(ctx, leaderboard.ID)
+	if db_err != nil {
+		return false, db_err
+	}
+	if secret == nil {
+		return false, nil
+	}
+
+	if len(sig.Signature) == 0 || len(sig.Nonce) == 0 || sig.Timestamp == 0 {
+		return false, huma.Error403Forbidden("This leaderboard requires signed submissions.")
+	}
+	signed_at := time.Unix(sig.Timestamp, 0)
+	if skew := time.Since(signed_at); skew > submission_signature_skew || skew < -submission_signature_skew {
+		return false, huma.Error403Forbidden("Submission timestamp is too far from the current time.")
+	}
+	expected := signSubmission(*secret, submissionSigningMessage(leaderboard.ShortID, user_id, score, sig.Nonce, sig.Timestamp))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(sig.Signature))) {
+		return false, huma.Error403Forbidden("Submission signature is invalid.")
+	}
+
+	fresh, db_err := app.st.useSubmissionNonce(ctx, leaderboard.ID, sig.Nonce)
+	if db_err != nil {
+		return false, db_err
+	}
+	if !fresh {
+		return false, huma.Error403Forbidden("Submission nonce has already been used.")
+	}
+	return true, nil
+}
+
+// releaseSubmissionNonce lets a nonce be used again after the score it signed
+// couldn't be saved, so the same signed request can be retried.
+func (app *App) releaseSubmissionNonce(ctx context.Context, leaderboard uuid.UUID, nonce string) {
+	if err := app.st.releaseSubmissionNonce(ctx, leaderboard, nonce); err != nil {
+		log.Printf("Could not release submission nonce: %v", err)
+	}
+}
+
+func newSubmissionSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "sss_" + hex.EncodeToString(b)
+}
+
+func (app *App) rotateSubmissionSecret(ctx context.Context, input *struct {
+	LeaderboardIDParam
+}) (*SubmissionSecretResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	secret := newSubmissionSecret()
+	if db_err := app.st.setSubmissionSecret(ctx, input.ID, &secret); db_err != nil {
+		return nil, db_err
+	}
+	resp := &SubmissionSecretResponse{}
+	resp.Body.Secret = secret
+	return resp, nil
+}
+
+func (app *App) removeSubmissionSecret(ctx context.Context, input *struct {
+	LeaderboardIDParam
+}) (*MessageResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	if db_err := app.st.setSubmissionSecret(ctx, input.ID, nil); db_err != nil {
+		return nil, db_err
+	}
+	resp := &MessageResponse{}
+	resp.Body.Message = "Submissions no longer need to be signed."
+	return resp, nil
+}
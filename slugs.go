@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+)
+
+var slug_pattern = regexp.MustCompile(`^[a-zA-Z0-9]+(-[a-zA-Z0-9]+)*$`)
+
+// Slugs that would be confused with pages and routes of the site or API.
+var reserved_slugs = map[string]bool{
+	"about": true, "account": true, "accounts": true, "admin": true, "api": true,
+	"create": true, "dashboard": true, "docs": true, "edit": true, "health": true,
+	"help": true, "info": true, "keys": true, "l": true, "leaderboard": true,
+	"leaderboards": true, "login": true, "logout": true, "me": true, "new": true,
+	"null": true, "openapi": true, "pricing": true, "privacy": true, "register": true,
+	"settings": true, "signin": true, "signup": true, "stream": true, "submission": true,
+	"submissions": true, "support": true, "terms": true, "undefined": true, "webhooks": true,
+	"www": true,
+}
+
+// isSlug reports whether s is shaped like a slug rather than a short ID or UUID.
+func isSlug(s string) bool {
+	if !slug_pattern.MatchString(s) {
+		return false
+	}
+	_, _, is_id := parseID(ids.leaderboards, s)
+	return !is_id
+}
+
+// validateSlug returns why slug can't be claimed, or nil if it can.
+func validateSlug(slug string) error {
+	detail := &huma.ErrorDetail{Location: "body.slug", Value: slug}
+	switch {
+	case !slug_pattern.MatchString(slug):
+		detail.Message = "slug may only contain letters, digits and single hyphens"
+	case !isSlug(slug):
+		detail.Message = "slug must not be a leaderboard ID"
+	case reserved_slugs[strings.ToLower(slug)]:
+		detail.Message = "slug is reserved"
+	default:
+		return nil
+	}
+	return huma.Error422UnprocessableEntity("Invalid slug.", detail)
+}
+
+// resolveSlug fills in the UUID and sequence number of the leaderboard a slug
+// belongs to, leaving them zero if it belongs to none.
+func resolveSlug(ctx huma.Context, slug string, id *uuid.UUID, seq *int64) []error {
+	st, ok := ctx.Context().Value(DB_CONTEXT_KEY).(DB)
+	if !ok {
+		return nil
+	}
+	if db_err := st.resolveSlug(ctx.Context(), slug, id, seq); db_err != nil {
+		return []error{huma.Error500InternalServerError("Could not look up slug.", db_err)}
+	}
+	return nil
+}
+
+func (app *App) setLeaderboardSlug(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	Body LeaderboardSlugBody
+}) (*LeaderboardInfoResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	if err := validateSlug(input.Body.Slug); err != nil {
+		return nil, err
+	}
+
+	claimed, db_err := app.st.setLeaderboardSlug(ctx, input.ID, input.Body.Slug)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if !claimed {
+		return nil, codedError(http.StatusConflict, error_code_already_exists, "Slug is taken by another leaderboard.")
+	}
+
+	return app.getLeaderboardInfo(ctx, &struct {
+		ConditionalParams
+		LeaderboardIDParam
+	}{LeaderboardIDParam: input.LeaderboardIDParam})
+}
+
+func (app *App) redirectSlug(ctx context.Context, input *struct {
+	Slug string `path:"slug" example:"speedrun-any-percent" doc:"Current or previous slug of a leaderboard."`
+}) (*SlugRedirectResponse, error) {
+	var id uuid.UUID
+	var seq int64
+	if isSlug(input.Slug) {
+		if db_err := app.st.resolveSlug(ctx, input.Slug, &id, &seq); db_err != nil {
+			return nil, db_err
+		}
+	}
+	if id.IsNil() {
+		return nil, huma.Error404NotFound("Leaderboard not found.")
+	}
+	if err := requireLeaderboardAccess(ctx, app.st, http.MethodGet, id, false); err != nil {
+		return nil, err
+	}
+	info, db_err := app.st.getLeaderboardInfo(ctx, id)
+	if db_err != nil {
+		return nil, db_err
+	}
+	// Others can't open unlisted leaderboards by short ID, so send them to
+	// the current slug instead.
+	if info.Visibility == "unlisted" && info.Slug != nil {
+		return &SlugRedirectResponse{Location: "/leaderboard/" + *info.Slug}, nil
+	}
+	return &SlugRedirectResponse{Location: "/leaderboard/" + LeaderboardShortID(seq).String()}, nil
+}
@@ -9,13 +9,14 @@ import (
 	"fmt"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2/humatest"
 	"github.com/gofrs/uuid/v5"
 	"github.com/stretchr/testify/assert"
 )
 
-func getSubmissionDetailed(t *testing.T, api humatest.TestAPI, leaderboard uuid.UUID, submission uuid.UUID) (DetailedSubmission, *httptest.ResponseRecorder) {
+func getSubmissionDetailed(t *testing.T, api humatest.TestAPI, leaderboard LeaderboardShortID, submission SubmissionShortID) (DetailedSubmission, *httptest.ResponseRecorder) {
 	t.Helper()
 	getResp := api.Get(fmt.Sprintf("/leaderboard/%s/submission/%s", leaderboard, submission))
 	var lResp DetailedSubmission
@@ -29,7 +30,7 @@ func TestGetSubmissionInfo(t *testing.T) {
 
 		postResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"score": 10,
 				"link":  "www.youtube.com",
@@ -41,7 +42,7 @@ func TestGetSubmissionInfo(t *testing.T) {
 		json.Unmarshal(postResp2.Body.Bytes(), &submitResponse)
 
 		if submitInfo, getResp := getSubmissionDetailed(t, api, id, submitResponse.ID); assert.Equal(t, 200, getResp.Code) {
-			assert.Equal(t, 10, submitInfo.Score)
+			assert.Equal(t, "10", submitInfo.Score.String())
 			assert.Equal(t, "www.youtube.com", submitInfo.Link)
 			assert.Equal(t, users["player2"], submitInfo.Submitter.ID)
 			assert.Equal(t, id, submitInfo.LeaderboardID)
@@ -51,62 +52,62 @@ func TestGetSubmissionInfo(t *testing.T) {
 	})
 }
 
-// func TestUpdateSubmissionBecomesUnverified(t *testing.T) {
-// 	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
-//
-// 		id := createBasicLeaderboard(t, api, users["player2"])
-//
-// 		postResp2 := api.Post(
-// 			fmt.Sprintf("/leaderboard/%s/submission", id),
-// 			fmt.Sprintf("UserID: %s", users["player2"]),
-// 			map[string]any{
-// 				"score": 10,
-// 				"link":  "www.youtube.com",
-// 			})
-//
-// 		assert.Equal(t, 200, postResp2.Code)
-//
-// 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
-// 			assert.Equal(t, 1, len(lResp.Scores))
-// 			assert.Equal(t, 10, lResp.Scores[0].Score)
-// 			assert.False(t, lResp.Scores[0].Verified)
-// 		}
-//
-// 		var submissionBody SubmissionResponseBody
-// 		json.Unmarshal(postResp2.Body.Bytes(), &submissionBody)
-// 		updateResp := api.Patch(
-// 			fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, submissionBody.ID),
-// 			fmt.Sprintf("UserID: %s", users["player2"]),
-// 			map[string]any{
-// 				"is_valid": true,
-// 			})
-// 		assert.Equal(t, 200, updateResp.Code)
-//
-// 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
-// 			assert.Equal(t, 1, len(lResp.Scores))
-// 			assert.Equal(t, 10, lResp.Scores[0].Score)
-// 			assert.True(t, lResp.Scores[0].Verified)
-// 		}
-//
-// 		var newScoreBody SubmissionResponseBody
-// 		json.Unmarshal(postResp2.Body.Bytes(), &newScoreBody)
-// 		updateRespScore := api.Patch(
-// 			fmt.Sprintf("/leaderboard/%s/submission/%s/score", id, newScoreBody.ID),
-// 			fmt.Sprintf("UserID: %s", users["player2"]),
-// 			map[string]any{
-// 				"score": 100,
-// 				"link":  "www.youtube.com",
-// 			})
-//
-// 		assert.Equal(t, 200, updateRespScore.Code)
-//
-// 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
-// 			assert.Equal(t, 1, len(lResp.Scores))
-// 			assert.Equal(t, 100, lResp.Scores[0].Score)
-// 			assert.False(t, lResp.Scores[0].Verified)
-// 		}
-// 	})
-// }
+func TestUpdateSubmissionBecomesUnverified(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+
+		id := createVerifiedLeaderboard(t, api, users["player2"])
+
+		postResp2 := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"score": 10,
+				"link":  "www.youtube.com",
+			})
+
+		assert.Equal(t, 200, postResp2.Code)
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Scores))
+			assert.Equal(t, "10", lResp.Scores[0].Score.String())
+			assert.False(t, *lResp.Scores[0].Verified)
+		}
+
+		var submissionBody SubmissionResponseBody
+		json.Unmarshal(postResp2.Body.Bytes(), &submissionBody)
+		updateResp := api.Patch(
+			fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, submissionBody.ID),
+			authHeader(users["player2"]),
+			map[string]any{
+				"is_valid": true,
+			})
+		assert.Equal(t, 200, updateResp.Code)
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Scores))
+			assert.Equal(t, "10", lResp.Scores[0].Score.String())
+			assert.True(t, *lResp.Scores[0].Verified)
+		}
+
+		var newScoreBody SubmissionResponseBody
+		json.Unmarshal(postResp2.Body.Bytes(), &newScoreBody)
+		updateRespScore := api.Patch(
+			fmt.Sprintf("/leaderboard/%s/submission/%s", id, newScoreBody.ID),
+			authHeader(users["player2"]),
+			map[string]any{
+				"score": 100,
+				"link":  "www.youtube.com",
+			})
+
+		assert.Equal(t, 200, updateRespScore.Code)
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Scores))
+			assert.Equal(t, "100", lResp.Scores[0].Score.String())
+			assert.False(t, *lResp.Scores[0].Verified)
+		}
+	})
+}
 
 func TestVerifyScoreNotOwner(t *testing.T) {
 	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
@@ -114,7 +115,7 @@ func TestVerifyScoreNotOwner(t *testing.T) {
 		id := createVerifiedLeaderboard(t, api, users["player2"])
 		postResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player3"]),
+			authHeader(users["player3"]),
 			map[string]any{
 				"score": 10,
 				"link":  "www.youtube.com",
@@ -125,13 +126,13 @@ func TestVerifyScoreNotOwner(t *testing.T) {
 		assert.Equal(t, 200, postResp2.Code)
 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 1, len(lResp.Scores))
-			assert.Equal(t, 10, lResp.Scores[0].Score)
+			assert.Equal(t, "10", lResp.Scores[0].Score.String())
 			assert.False(t, *lResp.Scores[0].Verified)
 		}
 
 		updateResp := api.Patch(
 			fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, newScoreBody.ID),
-			fmt.Sprintf("UserID: %s", users["Anonymous1"]),
+			authHeader(users["Anonymous1"]),
 			map[string]any{
 				"is_valid": true,
 			})
@@ -146,7 +147,7 @@ func TestVerifyScore(t *testing.T) {
 
 		postResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"score": 10,
 				"link":  "www.youtube.com",
@@ -156,7 +157,7 @@ func TestVerifyScore(t *testing.T) {
 
 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 1, len(lResp.Scores))
-			assert.Equal(t, 10, lResp.Scores[0].Score)
+			assert.Equal(t, "10", lResp.Scores[0].Score.String())
 			assert.False(t, *lResp.Scores[0].Verified)
 		}
 
@@ -164,7 +165,7 @@ func TestVerifyScore(t *testing.T) {
 		json.Unmarshal(postResp2.Body.Bytes(), &newScoreBody)
 		updateResp := api.Patch(
 			fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, newScoreBody.ID),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"is_valid": true,
 			})
@@ -172,61 +173,151 @@ func TestVerifyScore(t *testing.T) {
 
 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
 			assert.Equal(t, 1, len(lResp.Scores))
-			assert.Equal(t, 10, lResp.Scores[0].Score)
+			assert.Equal(t, "10", lResp.Scores[0].Score.String())
 			assert.True(t, *lResp.Scores[0].Verified)
 		}
 	})
 
 }
 
-// func TestUpdateScore(t *testing.T) {
-// 	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
-//
-// 		id := createBasicLeaderboard(t, api, users["player2"])
-// 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
-// 			assert.Zero(t, len(lResp.Scores))
-// 		}
-//
-// 		postResp2 := api.Post(
-// 			fmt.Sprintf("/leaderboard/%s/submission", id),
-// 			fmt.Sprintf("UserID: %s", users["player2"]),
-// 			map[string]any{
-// 				"score": 10,
-// 				"link":  "www.youtube.com",
-// 			})
-//
-// 		assert.Equal(t, 200, postResp2.Code)
-// 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
-// 			assert.Equal(t, 1, len(lResp.Scores))
-// 			assert.Equal(t, 10, lResp.Scores[0].Score)
-// 		}
-//
-// 		var newScoreBody SubmissionResponseBody
-// 		json.Unmarshal(postResp2.Body.Bytes(), &newScoreBody)
-// 		postResp3 := api.Patch(
-// 			fmt.Sprintf("/leaderboard/%s/submission/%s/score", id, newScoreBody.ID),
-// 			fmt.Sprintf("UserID: %s", users["player2"]),
-// 			map[string]any{
-// 				"score": 11,
-// 				"link":  "www.youtube.com/1",
-// 			})
-// 		assert.Equal(t, 200, postResp3.Code)
-//
-// 		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
-// 			assert.Equal(t, 1, len(lResp.Scores))
-// 			assert.Equal(t, 11, lResp.Scores[0].Score)
-// 		}
-//
-// 		if submitInfo, getResp := getSubmissionDetailed(t, api, id, newScoreBody.ID); assert.Equal(t, 200, getResp.Code) {
-// 			assert.Equal(t, 11, submitInfo.Score)
-// 			assert.Equal(t, "www.youtube.com/1", submitInfo.Link)
-// 			assert.Equal(t, users["player2"], submitInfo.Submitter.ID)
-// 			assert.Equal(t, id, submitInfo.LeaderboardID)
-// 			assert.Equal(t, "My First Leaderboard", submitInfo.LeaderboardDisplayName)
-// 			assert.False(t, submitInfo.Verified)
-// 		}
-// 	})
-// }
+func TestUpdateScore(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+
+		id := createBasicLeaderboard(t, api, users["player2"])
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Zero(t, len(lResp.Scores))
+		}
+
+		postResp2 := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"score": 10,
+				"link":  "www.youtube.com",
+			})
+
+		assert.Equal(t, 200, postResp2.Code)
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Scores))
+			assert.Equal(t, "10", lResp.Scores[0].Score.String())
+		}
+
+		var newScoreBody SubmissionResponseBody
+		json.Unmarshal(postResp2.Body.Bytes(), &newScoreBody)
+		postResp3 := api.Patch(
+			fmt.Sprintf("/leaderboard/%s/submission/%s", id, newScoreBody.ID),
+			authHeader(users["player2"]),
+			map[string]any{
+				"score": 11,
+				"link":  "www.youtube.com/1",
+			})
+		assert.Equal(t, 200, postResp3.Code)
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Scores))
+			assert.Equal(t, "11", lResp.Scores[0].Score.String())
+		}
+
+		if submitInfo, getResp := getSubmissionDetailed(t, api, id, newScoreBody.ID); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, "11", submitInfo.Score.String())
+			assert.Equal(t, "www.youtube.com/1", submitInfo.Link)
+			assert.Equal(t, users["player2"], submitInfo.Submitter.ID)
+			assert.Equal(t, id, submitInfo.LeaderboardID)
+			assert.Equal(t, "My First Leaderboard", submitInfo.LeaderboardDisplayName)
+			assert.False(t, submitInfo.Verified)
+		}
+
+		if lResp, getResp := getSubmissionHistory(t, api, id, newScoreBody.ID); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.History))
+			assert.Equal(t, "edit", lResp.History[0].Action)
+			assert.Equal(t, "10", lResp.History[0].PreviousScore.String())
+			assert.Equal(t, "11", lResp.History[0].Score.String())
+			assert.Equal(t, "www.youtube.com", *lResp.History[0].PreviousLink)
+			assert.Equal(t, "www.youtube.com/1", *lResp.History[0].Link)
+		}
+	})
+}
+
+func TestUpdateScoreNotOwner(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player3"]),
+			map[string]any{
+				"score": 10,
+				"link":  "www.youtube.com",
+			})
+		assert.Equal(t, 200, postResp.Code)
+
+		var newScoreBody SubmissionResponseBody
+		json.Unmarshal(postResp.Body.Bytes(), &newScoreBody)
+
+		updateResp := api.Patch(
+			fmt.Sprintf("/leaderboard/%s/submission/%s", id, newScoreBody.ID),
+			authHeader(users["player2"]),
+			map[string]any{
+				"score": 100,
+			})
+		assert.Equal(t, 403, updateResp.Code)
+
+		deleteResp := api.Delete(
+			fmt.Sprintf("/leaderboard/%s/submission/%s", id, newScoreBody.ID),
+			authHeader(users["player2"]))
+		assert.Equal(t, 403, deleteResp.Code)
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.Scores))
+			assert.Equal(t, "10", lResp.Scores[0].Score.String())
+		}
+	})
+}
+
+func TestWithdrawSubmission(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player3"]),
+			map[string]any{
+				"score": 10,
+				"link":  "www.youtube.com",
+			})
+		assert.Equal(t, 200, postResp.Code)
+
+		var newScoreBody SubmissionResponseBody
+		json.Unmarshal(postResp.Body.Bytes(), &newScoreBody)
+
+		deleteResp := api.Delete(
+			fmt.Sprintf("/leaderboard/%s/submission/%s", id, newScoreBody.ID),
+			authHeader(users["player3"]))
+		assert.Equal(t, 200, deleteResp.Code)
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Zero(t, len(lResp.Scores))
+		}
+
+		if submissionResp, getResp := getAccountSubmissions(t, api, users["player3"]); assert.Equal(t, 200, getResp.Code) {
+			assert.Zero(t, len(submissionResp.Submissions))
+		}
+
+		if submitInfo, getResp := getSubmissionDetailed(t, api, id, newScoreBody.ID); assert.Equal(t, 200, getResp.Code) {
+			assert.NotNil(t, submitInfo.TimeWithdrawn)
+		}
+
+		if lResp, getResp := getSubmissionHistory(t, api, id, newScoreBody.ID); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 1, len(lResp.History))
+			assert.Equal(t, "withdraw", lResp.History[0].Action)
+		}
+
+		deleteAgain := api.Delete(
+			fmt.Sprintf("/leaderboard/%s/submission/%s", id, newScoreBody.ID),
+			authHeader(users["player3"]))
+		assert.Equal(t, 404, deleteAgain.Code)
+	})
+}
 
 func TestAddSubmissionCommentFromVerifier(t *testing.T) {
 	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
@@ -234,7 +325,7 @@ func TestAddSubmissionCommentFromVerifier(t *testing.T) {
 
 		postResp := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player3"]),
+			authHeader(users["player3"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 9,
@@ -248,7 +339,7 @@ func TestAddSubmissionCommentFromVerifier(t *testing.T) {
 		assert.Equal(t, 200, postResp.Code)
 		postResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission/%s/comment", id, newScoreBody.ID),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"comment": "Great Job!",
 			})
@@ -262,13 +353,43 @@ func TestAddSubmissionCommentFromVerifier(t *testing.T) {
 	})
 }
 
+func TestAddSubmissionCommentFromNonVerifier(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createVerifiedLeaderboard(t, api, users["player2"])
+
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player3"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 9,
+			})
+		assert.Equal(t, 200, postResp.Code)
+
+		var newScoreBody SubmissionResponseBody
+		json.Unmarshal(postResp.Body.Bytes(), &newScoreBody)
+
+		commentResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission/%s/comment", id, newScoreBody.ID),
+			authHeader(users["player3"]),
+			map[string]any{
+				"comment": "Great Job!",
+			})
+		assert.Equal(t, 403, commentResp.Code)
+
+		if lResp, getResp := getSubmissionHistory(t, api, id, newScoreBody.ID); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 0, len(lResp.History))
+		}
+	})
+}
+
 func TestAddSubmissionInvalidateFromVerifier(t *testing.T) {
 	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
 		id := createBasicLeaderboard(t, api, users["player2"])
 
 		postResp := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player3"]),
+			authHeader(users["player3"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 9,
@@ -282,7 +403,7 @@ func TestAddSubmissionInvalidateFromVerifier(t *testing.T) {
 		assert.Equal(t, 200, postResp.Code)
 		postResp2 := api.Patch(
 			fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, newScoreBody.ID),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"comment":  "Great Job!",
 				"is_valid": false,
@@ -303,7 +424,7 @@ func TestGetSubmissionHistory(t *testing.T) {
 
 		postResp := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission", id),
-			fmt.Sprintf("UserID: %s", users["player3"]),
+			authHeader(users["player3"]),
 			map[string]any{
 				"link":  "www.youtube.com",
 				"score": 9,
@@ -317,7 +438,7 @@ func TestGetSubmissionHistory(t *testing.T) {
 		assert.Equal(t, 200, postResp.Code)
 		postResp2 := api.Post(
 			fmt.Sprintf("/leaderboard/%s/submission/%s/comment", id, newScoreBody.ID),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"comment": "Needs work.",
 			})
@@ -331,7 +452,7 @@ func TestGetSubmissionHistory(t *testing.T) {
 		assert.Equal(t, 200, postResp.Code)
 		validateResp := api.Patch(
 			fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, newScoreBody.ID),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"is_valid": true,
 				"comment":  "Great Job!",
@@ -349,7 +470,7 @@ func TestGetSubmissionHistory(t *testing.T) {
 
 		invalidateResp := api.Patch(
 			fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, newScoreBody.ID),
-			fmt.Sprintf("UserID: %s", users["player2"]),
+			authHeader(users["player2"]),
 			map[string]any{
 				"is_valid": false,
 				"comment":  "Caught Cheating",
@@ -366,3 +487,465 @@ func TestGetSubmissionHistory(t *testing.T) {
 		}
 	})
 }
+
+func TestDecimalScores(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		resp := api.Post("/leaderboard",
+			authHeader(users["player2"]),
+			map[string]any{
+				"title":         "Decimal Leaderboard",
+				"highest_first": true,
+				"precision":     9,
+				"start":         time.Now().Format(time.RFC3339),
+			})
+		if !assert.Equal(t, 200, resp.Code) {
+			return
+		}
+		var created NewLeaderboardResponseBody
+		json.Unmarshal(resp.Body.Bytes(), &created)
+		id := created.Id
+
+		for _, score := range []string{"98.75", "98.7", "123456789012345678.123456789", "98.750"} {
+			postResp := api.Post(
+				fmt.Sprintf("/leaderboard/%s/submission", id),
+				authHeader(users["player2"]),
+				json.RawMessage(fmt.Sprintf(`{"link": "www.youtube.com", "score": %s}`, score)))
+			assert.Equal(t, 200, postResp.Code, score)
+		}
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) && assert.Equal(t, 4, len(lResp.Scores)) {
+			assert.Equal(t, "123456789012345678.123456789", lResp.Scores[0].Score.String())
+			assert.Equal(t, "98.75", lResp.Scores[1].Score.String())
+			assert.Equal(t, "98.75", lResp.Scores[2].Score.String())
+			assert.Equal(t, 2, lResp.Scores[2].Rank)
+			assert.Equal(t, "98.7", lResp.Scores[3].Score.String())
+		}
+
+		tooPrecise := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player2"]),
+			json.RawMessage(`{"link": "www.youtube.com", "score": 1.0000000001}`))
+		assert.Equal(t, 422, tooPrecise.Code)
+
+		notNumber := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": "98.75",
+			})
+		assert.Equal(t, 422, notNumber.Code)
+
+		wholeResp := api.Post("/leaderboard",
+			authHeader(users["player2"]),
+			map[string]any{
+				"title":         "Whole Leaderboard",
+				"highest_first": true,
+				"start":         time.Now().Format(time.RFC3339),
+			})
+		if !assert.Equal(t, 200, wholeResp.Code) {
+			return
+		}
+		var whole NewLeaderboardResponseBody
+		json.Unmarshal(wholeResp.Body.Bytes(), &whole)
+		wholeID := whole.Id
+		fractional := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", wholeID),
+			authHeader(users["player2"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 9.5,
+			})
+		assert.Equal(t, 422, fractional.Code)
+
+		trailingZeros := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", wholeID),
+			authHeader(users["player2"]),
+			json.RawMessage(`{"link": "www.youtube.com", "score": 9.00}`))
+		assert.Equal(t, 200, trailingZeros.Code)
+	})
+}
+
+func TestTimeScores(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		resp := api.Post("/leaderboard",
+			authHeader(users["player2"]),
+			map[string]any{
+				"title":         "Any% Speedrun",
+				"highest_first": false,
+				"is_time":       true,
+				"start":         time.Now().Format(time.RFC3339),
+			})
+		if !assert.Equal(t, 200, resp.Code) {
+			return
+		}
+		var created NewLeaderboardResponseBody
+		json.Unmarshal(resp.Body.Bytes(), &created)
+		id := created.Id
+
+		var clockID SubmissionShortID
+		for _, score := range []any{"1:02:33.450", "PT2M5.5S", 61.25} {
+			postResp := api.Post(
+				fmt.Sprintf("/leaderboard/%s/submission", id),
+				authHeader(users["player2"]),
+				map[string]any{
+					"link":  "www.youtube.com",
+					"score": score,
+				})
+			if assert.Equal(t, 200, postResp.Code, score) && score == "1:02:33.450" {
+				var body SubmissionResponseBody
+				json.Unmarshal(postResp.Body.Bytes(), &body)
+				clockID = body.ID
+			}
+		}
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) && assert.Equal(t, 3, len(lResp.Scores)) {
+			assert.Equal(t, "61.25", lResp.Scores[0].Score.String())
+			assert.Equal(t, "1:01.250", lResp.Scores[0].DisplayScore)
+			assert.Equal(t, "125.5", lResp.Scores[1].Score.String())
+			assert.Equal(t, "2:05.500", lResp.Scores[1].DisplayScore)
+			assert.Equal(t, "3753.45", lResp.Scores[2].Score.String())
+			assert.Equal(t, "1:02:33.450", lResp.Scores[2].DisplayScore)
+		}
+
+		if submitInfo, getResp := getSubmissionDetailed(t, api, id, clockID); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, "3753.45", submitInfo.Score.String())
+			assert.Equal(t, "1:02:33.450", submitInfo.DisplayScore)
+		}
+
+		for _, score := range []any{"1:75", "1:02:33.4501", "P1M", "soon", -5} {
+			badResp := api.Post(
+				fmt.Sprintf("/leaderboard/%s/submission", id),
+				authHeader(users["player2"]),
+				map[string]any{
+					"link":  "www.youtube.com",
+					"score": score,
+				})
+			assert.Equal(t, 422, badResp.Code, score)
+		}
+
+		pointsResp := api.Post("/leaderboard",
+			authHeader(users["player2"]),
+			map[string]any{
+				"title":         "Points",
+				"highest_first": true,
+				"is_time":       false,
+				"start":         time.Now().Format(time.RFC3339),
+			})
+		if !assert.Equal(t, 200, pointsResp.Code) {
+			return
+		}
+		var points NewLeaderboardResponseBody
+		json.Unmarshal(pointsResp.Body.Bytes(), &points)
+
+		timeOnPoints := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", points.Id),
+			authHeader(users["player2"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": "1:02",
+			})
+		assert.Equal(t, 422, timeOnPoints.Code)
+	})
+}
+
+func signedHeaders(secret string, leaderboard LeaderboardShortID, user_id string, score string, nonce string, signed_at time.Time) []any {
+	s, _ := NewScore(score)
+	message := submissionSigningMessage(leaderboard, user_id, s, nonce, signed_at.Unix())
+	return []any{
+		"X-Submission-Signature: " + signSubmission(secret, message),
+		"X-Submission-Nonce: " + nonce,
+		fmt.Sprintf("X-Submission-Timestamp: %d", signed_at.Unix()),
+	}
+}
+
+func TestSignedSubmissions(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+		path := fmt.Sprintf("/leaderboard/%s/submission", id)
+		player := users["player1"]
+		post := func(headers ...any) int {
+			args := append([]any{authHeader(player)}, headers...)
+			args = append(args, map[string]any{"link": "www.youtube.com", "score": 12.50})
+			return api.Post(path, args...).Code
+		}
+
+		// Headers are ignored until the leaderboard has a secret.
+		assert.Equal(t, 200, post())
+
+		notOwnerResp := api.Post(fmt.Sprintf("/leaderboard/%s/signing-secret", id), authHeader(player))
+		assert.Equal(t, 403, notOwnerResp.Code)
+		secretResp := api.Post(fmt.Sprintf("/leaderboard/%s/signing-secret", id), authHeader(users["player2"]))
+		if !assert.Equal(t, 200, secretResp.Code) {
+			return
+		}
+		var body struct {
+			Secret string `json:"secret"`
+		}
+		json.Unmarshal(secretResp.Body.Bytes(), &body)
+		secret := body.Secret
+
+		if info, infoResp := getLeaderboardInfo(t, api, id); assert.Equal(t, 200, infoResp.Code) {
+			assert.True(t, info.SignedScores)
+		}
+
+		now := time.Now()
+		assert.Equal(t, 403, post())
+		assert.Equal(t, 200, post(signedHeaders(secret, id, player, "12.5", "nonce-1", now)...))
+		assert.Equal(t, 403, post(signedHeaders(secret, id, player, "12.5", "nonce-1", now)...), "replayed nonce")
+		assert.Equal(t, 403, post(signedHeaders(secret, id, player, "99", "nonce-2", now)...), "different score")
+		assert.Equal(t, 403, post(signedHeaders(secret, id, users["player3"], "12.5", "nonce-3", now)...), "different player")
+		assert.Equal(t, 403, post(signedHeaders("wrong", id, player, "12.5", "nonce-4", now)...), "wrong secret")
+		assert.Equal(t, 403, post(signedHeaders(secret, id, player, "12.5", "nonce-5", now.Add(-time.Hour))...), "stale")
+		assert.Equal(t, 200, post(signedHeaders(secret, id, player, "12.5", "nonce-6", now.Add(time.Minute))...), "within skew")
+
+		// Edited scores need signing too.
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			edit := fmt.Sprintf("%s/%s", path, lResp.Scores[0].ID)
+			editResp := api.Patch(edit, authHeader(player), map[string]any{"score": 1000})
+			assert.Equal(t, 403, editResp.Code)
+			args := append([]any{authHeader(player)}, signedHeaders(secret, id, player, "1000", "nonce-7", now)...)
+			editResp = api.Patch(edit, append(args, map[string]any{"score": 1000})...)
+			assert.Equal(t, 200, editResp.Code)
+		}
+
+		removeResp := api.Delete(fmt.Sprintf("/leaderboard/%s/signing-secret", id), authHeader(users["player2"]))
+		assert.Equal(t, 200, removeResp.Code)
+		assert.Equal(t, 200, post())
+	})
+}
+
+func TestIdempotentSubmissions(t *testing.T) {
+	WithAppState(t, func(ctx context.Context, app *App, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+		path := fmt.Sprintf("/leaderboard/%s/submission", id)
+		post := func(key string, score float64) (*httptest.ResponseRecorder, SubmissionShortID) {
+			resp := api.Post(path, authHeader(users["player1"]), "Idempotency-Key: "+key, map[string]any{"link": "www.youtube.com", "score": score})
+			var body SubmissionResponseBody
+			json.Unmarshal(resp.Body.Bytes(), &body)
+			return resp, body.ID
+		}
+
+		firstResp, first := post("retry-1", 12.5)
+		if !assert.Equal(t, 200, firstResp.Code) {
+			return
+		}
+		retryResp, retried := post("retry-1", 12.5)
+		assert.Equal(t, 200, retryResp.Code)
+		assert.Equal(t, first, retried, "retry replays the original submission")
+
+		otherResp, other := post("retry-2", 12.5)
+		assert.Equal(t, 200, otherResp.Code)
+		assert.NotEqual(t, first, other)
+
+		changedResp, _ := post("retry-1", 99)
+		assert.Equal(t, 422, changedResp.Code, "key reused with a different body")
+
+		if lResp, getResp := getLeaderboard(t, api, id); assert.Equal(t, 200, getResp.Code) {
+			assert.Equal(t, 2, len(lResp.Scores))
+		}
+
+		// Keys can be reused once their window has passed.
+		app.idempotencyWindow = time.Millisecond
+		time.Sleep(5 * time.Millisecond)
+		expiredResp, expired := post("retry-1", 99)
+		assert.Equal(t, 200, expiredResp.Code)
+		assert.NotEqual(t, first, expired)
+
+		// A request that never saved its response only holds the key for the
+		// lease, not the whole window.
+		app.idempotencyWindow = default_idempotency_window
+		crashed_at := time.Now().Add(-2 * idempotency_lease)
+		reserved, err := app.st.reserveIdempotencyKey(ctx, users["player1"], "crashed", "", crashed_at, crashed_at.Add(-app.idempotencyWindow), crashed_at.Add(-idempotency_lease))
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+		crashedResp, _ := post("crashed", 7)
+		assert.Equal(t, 200, crashedResp.Code)
+	})
+}
+
+func TestIdempotentLeaderboardCreation(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		post := func(user string, key string, title string) (*httptest.ResponseRecorder, LeaderboardShortID) {
+			resp := api.Post("/leaderboard", authHeader(user), "Idempotency-Key: "+key, map[string]any{
+				"title":         title,
+				"highest_first": true,
+				"is_time":       false,
+				"start":         "2024-01-01T00:00:00Z",
+				"verify":        false,
+			})
+			var body NewLeaderboardResponseBody
+			json.Unmarshal(resp.Body.Bytes(), &body)
+			return resp, body.Id
+		}
+
+		firstResp, first := post(users["player1"], "create-1", "Idempotent")
+		if !assert.Equal(t, 200, firstResp.Code) {
+			return
+		}
+		retryResp, retried := post(users["player1"], "create-1", "Idempotent")
+		assert.Equal(t, 200, retryResp.Code)
+		assert.Equal(t, first, retried)
+
+		changedResp, _ := post(users["player1"], "create-1", "Changed")
+		assert.Equal(t, 422, changedResp.Code)
+
+		// Keys are scoped to the user sending them.
+		otherResp, other := post(users["player2"], "create-1", "Idempotent")
+		assert.Equal(t, 200, otherResp.Code)
+		assert.NotEqual(t, first, other)
+	})
+}
+
+func TestSubmissionErrorCodes(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player1"])
+		missing := uuid.Must(uuid.NewV4())
+		var body struct {
+			Code string `json:"code"`
+		}
+
+		getResp := api.Get(fmt.Sprintf("/leaderboard/%s/submission/%s", id, missing))
+		assert.Equal(t, 404, getResp.Code)
+		json.Unmarshal(getResp.Body.Bytes(), &body)
+		assert.Equal(t, "not_found", body.Code)
+
+		forbiddenResp := api.Post(fmt.Sprintf("/leaderboard/%s/signing-secret", id), authHeader(users["player2"]))
+		assert.Equal(t, 403, forbiddenResp.Code)
+		json.Unmarshal(forbiddenResp.Body.Bytes(), &body)
+		assert.Equal(t, "forbidden", body.Code)
+
+		verifyResp := api.Patch(fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, missing), authHeader(users["player1"]), map[string]any{
+			"is_valid": true,
+		})
+		assert.Equal(t, 404, verifyResp.Code)
+	})
+}
+
+func TestRestrictedEntry(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["admin"])
+		leaderboard := fmt.Sprintf("/leaderboard/%s", id)
+		submission := leaderboard + "/submission"
+		score := map[string]any{"link": "www.youtube.com", "score": 10}
+		setEntry := func(entry string) {
+			t.Helper()
+			resp := api.Patch(leaderboard, authHeader(users["admin"]), map[string]any{"entry": entry})
+			assert.Equal(t, 200, resp.Code)
+		}
+
+		assert.Equal(t, 400, api.Post(leaderboard+"/join", authHeader(users["player2"]), map[string]any{}).Code)
+
+		// Invite codes admit entrants until they're used up, expire or are revoked.
+		setEntry("invite")
+		assert.Equal(t, 403, api.Post(submission, authHeader(users["player2"]), score).Code)
+		assert.Equal(t, 403, api.Post(leaderboard+"/join", authHeader(users["player2"]), map[string]any{}).Code)
+		assert.Equal(t, 403, api.Post(leaderboard+"/invites", authHeader(users["player2"]), map[string]any{}).Code)
+		assert.Equal(t, 422, api.Post(leaderboard+"/invites", authHeader(users["admin"]), map[string]any{
+			"expires_at": time.Now().Add(-time.Hour).Format(time.RFC3339),
+		}).Code)
+
+		inviteResp := api.Post(leaderboard+"/invites", authHeader(users["admin"]), map[string]any{"max_uses": 1})
+		if !assert.Equal(t, 200, inviteResp.Code) {
+			return
+		}
+		var invite Invite
+		json.Unmarshal(inviteResp.Body.Bytes(), &invite)
+		assert.NotNil(t, invite.TimeExpires)
+
+		redeemResp := api.Post("/invites/"+invite.Code, authHeader(users["player2"]))
+		if assert.Equal(t, 200, redeemResp.Code) {
+			assert.Contains(t, redeemResp.Body.String(), `"status":"joined"`)
+			assert.Contains(t, redeemResp.Body.String(), id.String())
+		}
+		assert.Equal(t, 404, api.Post("/invites/"+invite.Code, authHeader(users["player3"])).Code)
+		assert.Equal(t, 200, api.Post(submission, authHeader(users["player2"]), score).Code)
+		assert.Contains(t, api.Get(leaderboard+"/invites", authHeader(users["admin"])).Body.String(), `"uses":1`)
+
+		revoked := api.Post(leaderboard+"/invites", authHeader(users["admin"]), map[string]any{})
+		json.Unmarshal(revoked.Body.Bytes(), &invite)
+		assert.Equal(t, 200, api.Delete(fmt.Sprintf("%s/invites/%s", leaderboard, invite.ID), authHeader(users["admin"])).Code)
+		assert.Equal(t, 404, api.Post("/invites/"+invite.Code, authHeader(users["player3"])).Code)
+
+		// With approval, users can also ask the creator to let them in.
+		setEntry("approval")
+		joinResp := api.Post(leaderboard+"/join", authHeader(users["player3"]), map[string]any{"message": "Team red"})
+		assert.Equal(t, 202, joinResp.Code)
+		assert.Equal(t, 202, api.Post(leaderboard+"/join", authHeader(users["player3"]), map[string]any{}).Code)
+		assert.Equal(t, 202, api.Post(leaderboard+"/join", authHeader(users["Anonymous1"]), map[string]any{}).Code)
+		requests := api.Get(leaderboard+"/join-requests", authHeader(users["admin"]))
+		assert.Contains(t, requests.Body.String(), "Team red")
+		assert.Equal(t, 403, api.Post(submission, authHeader(users["player3"]), score).Code)
+
+		assert.Equal(t, 200, api.Post(fmt.Sprintf("%s/join-requests/%s", leaderboard, users["player3"]), authHeader(users["admin"])).Code)
+		assert.Equal(t, 404, api.Post(fmt.Sprintf("%s/join-requests/%s", leaderboard, users["player3"]), authHeader(users["admin"])).Code)
+		assert.Equal(t, 200, api.Post(submission, authHeader(users["player3"]), score).Code)
+		memberResp := api.Post(leaderboard+"/join", authHeader(users["player3"]), map[string]any{})
+		assert.Equal(t, 200, memberResp.Code)
+		assert.Contains(t, memberResp.Body.String(), `"status":"member"`)
+
+		assert.Equal(t, 200, api.Delete(fmt.Sprintf("%s/join-requests/%s", leaderboard, users["Anonymous1"]), authHeader(users["admin"])).Code)
+		assert.Equal(t, 404, api.Delete(leaderboard+"/join-requests/nobody", authHeader(users["admin"])).Code)
+		assert.Equal(t, 403, api.Post(submission, authHeader(users["Anonymous1"]), score).Code)
+	})
+}
+
+func TestSubmissionWindow(t *testing.T) {
+	WithApp(t, func(ctx context.Context, api humatest.TestAPI, users map[string]string) {
+		score := map[string]any{"link": "www.youtube.com", "score": 62}
+		submit := func(id LeaderboardShortID) *httptest.ResponseRecorder {
+			t.Helper()
+			return api.Post(fmt.Sprintf("/leaderboard/%s/submission", id), authHeader(users["player2"]), score)
+		}
+		now := time.Now()
+
+		upcoming := createLeaderboardTimeLimit(t, api, users["admin"], now.Add(time.Hour).Format(time.RFC3339), now.Add(2*time.Hour).Format(time.RFC3339))
+		if info, resp := getLeaderboardInfo(t, api, upcoming); assert.Equal(t, 200, resp.Code) {
+			assert.Equal(t, "upcoming", info.State)
+		}
+		upcomingResp := submit(upcoming)
+		assert.Equal(t, 403, upcomingResp.Code)
+		assert.Contains(t, upcomingResp.Body.String(), `"code":"leaderboard_upcoming"`)
+
+		closed := createLeaderboardTimeLimit(t, api, users["admin"], now.Add(-2*time.Hour).Format(time.RFC3339), now.Add(-time.Hour).Format(time.RFC3339))
+		if info, resp := getLeaderboardInfo(t, api, closed); assert.Equal(t, 200, resp.Code) {
+			assert.Equal(t, "closed", info.State)
+		}
+		closedResp := submit(closed)
+		assert.Equal(t, 403, closedResp.Code)
+		assert.Contains(t, closedResp.Body.String(), `"code":"leaderboard_closed"`)
+
+		// Late submissions are accepted until the grace period is over.
+		graceResp := api.Patch(fmt.Sprintf("/leaderboard/%s", closed), authHeader(users["admin"]), map[string]any{"grace_period": 7200})
+		assert.Equal(t, 200, graceResp.Code)
+		if info, resp := getLeaderboardInfo(t, api, closed); assert.Equal(t, 200, resp.Code) {
+			assert.Equal(t, "open", info.State)
+			assert.Equal(t, 7200, info.GracePeriod)
+		}
+		lateResp := submit(closed)
+		if !assert.Equal(t, 200, lateResp.Code) {
+			return
+		}
+		var late SubmissionResponseBody
+		json.Unmarshal(lateResp.Body.Bytes(), &late)
+
+		// Once the grace period is over, submissions can't be edited either,
+		// since edits keep their original time and would change the ranking.
+		assert.Equal(t, 200, api.Patch(fmt.Sprintf("/leaderboard/%s", closed), authHeader(users["admin"]), map[string]any{"grace_period": 0}).Code)
+		lateEdit := fmt.Sprintf("/leaderboard/%s/submission/%s", closed, late.ID)
+		editResp := api.Patch(lateEdit, authHeader(users["player2"]), map[string]any{"score": 61})
+		assert.Equal(t, 403, editResp.Code)
+		assert.Contains(t, editResp.Body.String(), `"code":"leaderboard_closed"`)
+		assert.Equal(t, 403, api.Patch(lateEdit, authHeader(users["player2"]), map[string]any{"link": "www.youtube.com/late"}).Code)
+
+		open := createDefaultLeaderboard(t, api, users["admin"])
+		if info, resp := getLeaderboardInfo(t, api, open); assert.Equal(t, 200, resp.Code) {
+			assert.Equal(t, "open", info.State)
+		}
+		assert.Equal(t, 200, api.Post(fmt.Sprintf("/leaderboard/%s/archive", open), authHeader(users["admin"])).Code)
+		if info, resp := getLeaderboardInfo(t, api, open); assert.Equal(t, 200, resp.Code) {
+			assert.Equal(t, "closed", info.State)
+		}
+		assert.Equal(t, 403, submit(open).Code)
+	})
+}
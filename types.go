@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 
+	"github.com/danielgtaylor/huma/v2"
 	"github.com/gofrs/uuid/v5"
 )
 
@@ -11,18 +13,76 @@ type NotAuthorizedResponse struct {
 	Body   MessageResponse
 }
 
+// LeaderboardIDParam takes a short leaderboard ID, one of the leaderboard's
+// slugs or a UUID. Both ID and ShortID are filled in when the leaderboard
+// exists.
 type LeaderboardIDParam struct {
-	ID uuid.UUID `path:"leaderboard_id" format:"uuid" example:"146b2edf-2d6f-4775-9b86-5537a2649589" doc:"Unique leaderboard ID used for querying." required:"true"`
+	RawID   string `path:"leaderboard_id" example:"tYLfjGTh9" doc:"Short leaderboard ID. The leaderboard's slug or UUID is also accepted, and needed for unlisted leaderboards unless you're their creator, a verifier or a member." required:"true"`
+	ID      uuid.UUID
+	ShortID LeaderboardShortID
 }
+
+// path is the leaderboard's path under the ID the request used, so links stay
+// usable by viewers who can't open an unlisted leaderboard by its short ID.
+func (p LeaderboardIDParam) path() string {
+	if len(p.RawID) == 0 {
+		return "/leaderboard/" + p.ShortID.String()
+	}
+	return "/leaderboard/" + p.RawID
+}
+
 type SubmissionIDParam struct {
-	SubmissionID uuid.UUID `path:"submission_id" format:"uuid" example:"146b2edf-2d6f-4775-9b86-5537a2649589" doc:"Unique submission ID used for querying." required:"true"`
+	RawSubmissionID   string `path:"submission_id" example:"A4xACrHGM" doc:"Short submission ID. The submission's UUID is also accepted." required:"true"`
+	SubmissionID      uuid.UUID
+	SubmissionShortID SubmissionShortID
+}
+type VerifierParam struct {
+	Verifier string `path:"verifier" required:"true" example:"greensuigi" doc:"User ID of the verifier, or their username if no user has that ID."`
 }
 type UserIDParam struct {
 	UserID string `path:"user_id" required:"true" example:"146b2edf-2d6f-4775-9b86-5537a2649589"`
 }
 
-type UserIDHeader struct {
-	UserID string `header:"UserID" required:"true"  example:"146b2edf-2d6f-4775-9b86-5537a2649589"`
+type PageParams struct {
+	Cursor string `query:"cursor" doc:"Opaque cursor taken from the previous page's Link header."`
+	Limit  int    `query:"limit" minimum:"1" maximum:"100" default:"25" doc:"Maximum number of entries to return."`
+}
+
+type RankingPageParams struct {
+	Cursor string `query:"cursor" doc:"Opaque cursor taken from the previous page's Link header."`
+	Limit  int    `query:"limit" minimum:"1" maximum:"1000" default:"100" doc:"Maximum number of rankings to return."`
+}
+
+type PeriodParam struct {
+	Period int `path:"period" minimum:"1" example:"3" doc:"Period number, starting from 1."`
+}
+
+type WebhookIDParam struct {
+	WebhookID uuid.UUID `path:"webhook_id" format:"uuid" example:"146b2edf-2d6f-4775-9b86-5537a2649589"`
+}
+
+type DeliveryIDParam struct {
+	DeliveryID uuid.UUID `path:"delivery_id" format:"uuid" example:"146b2edf-2d6f-4775-9b86-5537a2649589"`
+}
+
+type APIKeyIDParam struct {
+	KeyID uuid.UUID `path:"key_id" format:"uuid" example:"146b2edf-2d6f-4775-9b86-5537a2649589"`
+}
+
+type InviteIDParam struct {
+	InviteID uuid.UUID `path:"invite_id" format:"uuid" example:"146b2edf-2d6f-4775-9b86-5537a2649589"`
+}
+
+type InviteCodeParam struct {
+	Code string `path:"code" example:"3f9a1c2e7b4d8a60" doc:"Invite code from the leaderboard's creator."`
+}
+
+type AroundParams struct {
+	N int `query:"n" minimum:"0" maximum:"50" default:"5" doc:"Number of entries to return above and below the submission."`
+}
+
+type StreamParams struct {
+	Limit int `query:"limit" minimum:"1" maximum:"100" default:"10" doc:"Number of top rankings sent with each rankings event."`
 }
 
 type CommentSubmissionBody struct {
@@ -39,8 +99,46 @@ type VerifyScoreBody struct {
 }
 type NewSubmissionRequest struct {
 	Body struct {
-		Link  string `json:"link" required:"true"`
-		Score int    `json:"score" required:"true"`
+		Link     string `json:"link" required:"true"`
+		Score    Score  `json:"score" required:"true"`
+		UserID   string `json:"user_id,omitempty" doc:"ID of the player to submit for. This or username is required with an API key, which may submit for any player; a user may only give their own."`
+		Username string `json:"username,omitempty" example:"greensuigi" doc:"Username of the player to submit for, if user_id is not given."`
+	}
+}
+
+type VerifierBody struct {
+	Body struct {
+		UserID   string `json:"user_id,omitempty" example:"146b2edf-2d6f-4775-9b86-5537a2649589" doc:"ID of the user to add as a verifier."`
+		Username string `json:"username,omitempty" example:"greensuigi" doc:"Username of the user to add as a verifier, if user_id is not given."`
+	}
+}
+
+type MemberBody struct {
+	Body struct {
+		UserID   string `json:"user_id,omitempty" example:"146b2edf-2d6f-4775-9b86-5537a2649589" doc:"ID of the user to add as a member."`
+		Username string `json:"username,omitempty" example:"greensuigi" doc:"Username of the user to add as a member, if user_id is not given."`
+	}
+}
+
+type UpdateSubmissionRequest struct {
+	Body struct {
+		Link    *string `json:"link,omitempty" doc:"New link for the submission. Unchanged if omitted."`
+		Score   *Score  `json:"score,omitempty" doc:"New score for the submission. Unchanged if omitted."`
+		Comment string  `json:"comment,omitempty" doc:"Reason for the edit, shown in the submission history."`
+	}
+}
+
+type NewWebhookBody struct {
+	Body struct {
+		URL    string   `json:"url" required:"true" format:"uri" example:"https://example.com/hooks/topktoday" doc:"HTTPS URL events are POSTed to."`
+		Events []string `json:"events" required:"true" minItems:"1" uniqueItems:"true" enum:"submission.created,submission.verified,submission.commented,leaderboard.closed" doc:"Events to send."`
+	}
+}
+
+type NewAPIKeyBody struct {
+	Body struct {
+		Name   string   `json:"name" required:"true" minLength:"1" maxLength:"100" example:"EU game servers"`
+		Scopes []string `json:"scopes" required:"true" minItems:"1" uniqueItems:"true" enum:"submit,verify,read" doc:"submit posts scores for any player, verify verifies submissions as the key's creator, read reads a private leaderboard or opens an unlisted one by short ID. Public leaderboards can be read without a key."`
 	}
 }
 
@@ -53,6 +151,10 @@ type LinkAnonymousBody struct {
 type NewLeaderboardBody struct {
 	Body LeaderboardConfig
 }
+
+type UpdateLeaderboardBody struct {
+	Body LeaderboardUpdate
+}
 type MessageResponseBody struct {
 	Message string `json:"message" example:"All systems go!" doc:"Human readable message."`
 }
@@ -61,15 +163,33 @@ type ErrorResponse struct {
 }
 
 type NewLeaderboardResponseBody struct {
-	Id uuid.UUID `json:"id" format:"uuid" example:"146b2edf-2d6f-4775-9b86-5537a2649589" doc:"Unique leaderboard ID used for querying."`
+	Id   LeaderboardShortID `json:"id" example:"tYLfjGTh9" doc:"Short leaderboard ID used for querying."`
+	UUID uuid.UUID          `json:"uuid" doc:"Full leaderboard ID. Others can only open an unlisted leaderboard with this or its slug."`
+}
+
+type LeaderboardMembersResponse struct {
+	Link string `header:"Link" doc:"Link to the next page of members, if any."`
+	Body struct {
+		Members []User `json:"members"`
+	}
 }
 
 type LeaderboardVerifiersResponse struct {
-	Body LeaderboardVerifiersResponseBody
+	Status int
+	ETag   string `header:"ETag"`
+	Link   string `header:"Link" doc:"Link to the next page of verifiers, if any."`
+	Body   LeaderboardVerifiersResponseBody
 }
 type LeaderboardVerifiersResponseBody struct {
 	Verifiers []User `json:"verifiers"`
 }
+type VerifierHistoryResponse struct {
+	Link string `header:"Link" doc:"Link to the next page of history, if any."`
+	Body VerifierHistoryResponseBody
+}
+type VerifierHistoryResponseBody struct {
+	History []VerifierHistoryEntry `json:"history" doc:"Verifier additions and removals, newest first."`
+}
 type NewLeaderboardResponse struct {
 	Body NewLeaderboardResponseBody
 }
@@ -83,10 +203,12 @@ type AccountSubmissionsResponseBody struct {
 }
 
 type AccountSubmissionsResponse struct {
+	Link string `header:"Link" doc:"Link to the next page of submissions, if any."`
 	Body AccountSubmissionsResponseBody
 }
 
 type AccountLeaderboardsResponse struct {
+	Link string `header:"Link" doc:"Link to the next page of leaderboards, if any."`
 	Body AccountLeaderboardsResponseBody
 }
 
@@ -97,15 +219,127 @@ type LeaderboardResponseBody struct {
 type LeaderboardResponse struct {
 	Status       int
 	LastModified time.Time `header:"Last-Modified"`
+	ETag         string    `header:"ETag"`
+	Link         string    `header:"Link" doc:"Link to the next page of rankings, if any."`
 	Body         *LeaderboardResponseBody
 }
 
+type LeaderboardPeriodResponseBody struct {
+	Period LeaderboardPeriod `json:"period"`
+	Scores []Ranking         `json:"scores"`
+}
+
+type LeaderboardPeriodResponse struct {
+	Link string `header:"Link" doc:"Link to the next page of rankings, if any."`
+	Body LeaderboardPeriodResponseBody
+}
+
+type UserRankResponse struct {
+	Body UserRank
+}
+
+type SubmissionsAroundResponse struct {
+	Body LeaderboardResponseBody
+}
+
+type SubmissionEvent struct {
+	Type         string            `json:"type" enum:"submission,update,verification,withdrawal" doc:"What happened to the submission."`
+	SubmissionID SubmissionShortID `json:"submission_id" example:"A4xACrHGM"`
+	Ranking      *Ranking          `json:"ranking,omitempty" doc:"Current ranking of the submission, omitted if it is no longer ranked."`
+}
+
+type StreamPing struct {
+	Time time.Time `json:"time" doc:"Server time, sent periodically to keep the connection open."`
+}
+
+type WebhookEndpointResponse struct {
+	Body WebhookEndpoint
+}
+
+type WebhookEndpointsResponse struct {
+	Body struct {
+		Webhooks []WebhookEndpoint `json:"webhooks"`
+	}
+}
+
+type WebhookDeliveryResponse struct {
+	Body WebhookDelivery
+}
+
+type WebhookDeliveriesResponse struct {
+	Link string `header:"Link" doc:"Link to the next page of deliveries, if any."`
+	Body struct {
+		Deliveries []WebhookDelivery `json:"deliveries" doc:"Deliveries, newest first."`
+	}
+}
+
+type APIKeyResponse struct {
+	Body APIKey
+}
+
+type APIKeysResponse struct {
+	Body struct {
+		Keys []APIKey `json:"keys"`
+	}
+}
+
+type NewInviteBody struct {
+	Body struct {
+		MaxUses   *int       `json:"max_uses,omitempty" minimum:"1" doc:"How many entrants can join with the code. Unlimited if omitted."`
+		ExpiresAt *time.Time `json:"expires_at,omitempty" format:"date-time" doc:"When the code stops working. A week after it's created if omitted."`
+	}
+}
+
+type InviteResponse struct {
+	Body Invite
+}
+
+type InvitesResponse struct {
+	Body struct {
+		Invites []Invite `json:"invites"`
+	}
+}
+
+type JoinBody struct {
+	Body struct {
+		Message string `json:"message,omitempty" maxLength:"500" doc:"Note for the leaderboard's creator."`
+	}
+}
+
+type JoinResponse struct {
+	Status int
+	Body   struct {
+		LeaderboardID LeaderboardShortID `json:"leaderboard_id" example:"tYLfjGTh9"`
+		Status        string             `json:"status" enum:"joined,member,pending" doc:"Whether the user joined, was already a member, or is waiting for the creator's approval."`
+	}
+}
+
+type JoinRequestsResponse struct {
+	Body struct {
+		Requests []JoinRequest `json:"requests"`
+	}
+}
+
+type LeaderboardSlugBody struct {
+	Slug string `json:"slug" minLength:"3" maxLength:"64" pattern:"^[a-zA-Z0-9]+(-[a-zA-Z0-9]+)*$" example:"speedrun-any-percent" doc:"Letters, digits and single hyphens. Unique regardless of case."`
+}
+
+type SlugRedirectResponse struct {
+	Location string `header:"Location"`
+}
+
+type SubmissionSecretResponse struct {
+	Body struct {
+		Secret string `json:"secret" doc:"Key to sign submissions with. Replaces any previous secret."`
+	}
+}
+
 type MessageResponse struct {
 	Body MessageResponseBody
 }
 
 type SubmissionResponseBody struct {
-	ID uuid.UUID `json:"submission_id" format:"uuid" example:"146b2edf-2d6f-4775-9b86-5537a2649589" doc:"Submission ID used for querying."`
+	ID SubmissionShortID `json:"submission_id" example:"A4xACrHGM" doc:"Short submission ID used for querying."`
 }
 
 type HistoryResponseBody struct {
@@ -127,16 +361,41 @@ type SubmissionInfoResponseBody struct {
 }
 
 type LeaderboardInfoResponse struct {
-	Body LeaderboardInfo
+	Status int
+	ETag   string `header:"ETag"`
+	Body   LeaderboardInfo
 }
 type SubmissionResponse struct {
 	Body SubmissionResponseBody
 }
 
 type HistoryResponse struct {
-	Body HistoryResponseBody
+	Status int
+	ETag   string `header:"ETag"`
+	Link   string `header:"Link" doc:"Link to the next page of history, if any."`
+	Body   HistoryResponseBody
 }
 
 type LeaderboardPostResponse struct {
 	Status int
 }
+
+// NullableTime is a time in a request body that tells an explicit null, which
+// clears it, apart from a missing field, which leaves it unchanged.
+type NullableTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+func (t NullableTime) Schema(r huma.Registry) *huma.Schema {
+	return &huma.Schema{Type: huma.TypeString, Format: "date-time", Nullable: true}
+}
+
+func (t NullableTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Time)
+}
+
+func (t *NullableTime) UnmarshalJSON(b []byte) error {
+	t.Set = true
+	return json.Unmarshal(b, &t.Time)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// isViewer reports whether the request is signed in as user_id.
+func isViewer(ctx context.Context, user_id string) bool {
+	user, ok := ctx.Value(USER_CONTEXT_KEY).(*User)
+	return ok && user != nil && user.ID == user_id
+}
+
+// requireLeaderboardAccess returns a 404 for a private leaderboard, or an
+// unlisted one looked up by_short_id, unless the request's user is its
+// creator, a verifier or a member. API keys for the
+// leaderboard need the read scope to read it, while other requests are left
+// for the handler to check the key's scope. Leaderboards that don't exist are
+// also left for the handler to report.
+func requireLeaderboardAccess(ctx context.Context, st DB, method string, leaderboard uuid.UUID, by_short_id bool) error {
+	key, _ := ctx.Value(API_KEY_CONTEXT_KEY).(*APIKey)
+	own_key := key != nil && key.Leaderboard == leaderboard
+	if own_key && (method != http.MethodGet || key.hasScope(scope_read)) {
+		return nil
+	}
+	user_id := ""
+	if user, ok := ctx.Value(USER_CONTEXT_KEY).(*User); ok && user != nil {
+		user_id = user.ID
+	}
+	allowed, db_err := st.canViewLeaderboard(ctx, leaderboard, user_id, by_short_id)
+	if db_err == pgx.ErrNoRows {
+		return nil
+	}
+	if db_err != nil {
+		return db_err
+	}
+	if !allowed && own_key {
+		return huma.Error403Forbidden("API key doesn't have the " + scope_read + " scope.")
+	}
+	if !allowed {
+		return huma.Error404NotFound("Leaderboard not found.")
+	}
+	return nil
+}
+
+func (app *App) getLeaderboardMembers(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	PageParams
+}) (*LeaderboardMembersResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	members, db_err := app.st.getMembers(ctx, input.ID, after, input.Limit+1)
+	if db_err != nil {
+		return nil, db_err
+	}
+	members, next := page(members, input.Limit, func(u User) Cursor {
+		c := Cursor{ID: u.ID}
+		if u.TimeAdded != nil {
+			c.Time = *u.TimeAdded
+		}
+		return c
+	})
+
+	resp := &LeaderboardMembersResponse{
+		Link: nextLink(input.path()+"/members", input.Limit, next),
+	}
+	resp.Body.Members = members
+	return resp, nil
+}
+
+func (app *App) addLeaderboardMember(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	MemberBody
+}) (*LeaderboardMembersResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+
+	if len(input.Body.UserID) == 0 && len(input.Body.Username) == 0 {
+		return nil, huma.Error400BadRequest("Provide a user_id or username.")
+	}
+	member, db_err := app.st.findUser(ctx, input.Body.UserID, input.Body.Username)
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("User not found.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+
+	count, db_err := app.st.addMember(ctx, input.ID, member.ID, user.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if count == 0 {
+		return nil, codedError(http.StatusConflict, error_code_already_exists, "User is already a member.")
+	}
+
+	return app.getLeaderboardMembers(ctx, &struct {
+		LeaderboardIDParam
+		PageParams
+	}{LeaderboardIDParam: input.LeaderboardIDParam, PageParams: PageParams{Limit: 25}})
+}
+
+func (app *App) removeLeaderboardMember(ctx context.Context, input *struct {
+	LeaderboardIDParam
+	UserIDParam
+}) (*LeaderboardMembersResponse, error) {
+	user, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.requireLeaderboardOwner(ctx, input.ID, user); err != nil {
+		return nil, err
+	}
+
+	member, db_err := app.st.findUser(ctx, input.UserID, "")
+	if db_err == pgx.ErrNoRows {
+		return nil, huma.Error404NotFound("User not found.")
+	}
+	if db_err != nil {
+		return nil, db_err
+	}
+	count, db_err := app.st.removeMember(ctx, input.ID, member.ID)
+	if db_err != nil {
+		return nil, db_err
+	}
+	if count == 0 {
+		return nil, huma.Error404NotFound("User is not a member.")
+	}
+
+	return app.getLeaderboardMembers(ctx, &struct {
+		LeaderboardIDParam
+		PageParams
+	}{LeaderboardIDParam: input.LeaderboardIDParam, PageParams: PageParams{Limit: 25}})
+}
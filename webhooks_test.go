@@ -8,11 +8,19 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/gofrs/uuid/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -134,3 +142,259 @@ func TestWebhook(t *testing.T) {
 		assert.Equal(t, 200, resp.Code)
 	})
 }
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver records webhooks POSTed to it, responding with status.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	received []receivedWebhook
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, receivedWebhook{req.Header.Clone(), body})
+	w.WriteHeader(r.status)
+}
+
+func (r *webhookReceiver) respondWith(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *webhookReceiver) take() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	received := r.received
+	r.received = nil
+	return received
+}
+
+func findDelivery(t *testing.T, api humatest.TestAPI, path string, user string, event string) WebhookDelivery {
+	t.Helper()
+	resp := api.Get(path, authHeader(user))
+	assert.Equal(t, 200, resp.Code)
+	var body struct {
+		Deliveries []WebhookDelivery `json:"deliveries"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	for _, d := range body.Deliveries {
+		if d.Event == event {
+			return d
+		}
+	}
+	t.Fatalf("no %s delivery in %s", event, resp.Body.String())
+	return WebhookDelivery{}
+}
+
+func TestOutboundWebhooks(t *testing.T) {
+	receiver := &webhookReceiver{status: 200}
+	server := httptest.NewTLSServer(receiver)
+	defer server.Close()
+
+	WithAppState(t, func(ctx context.Context, app *App, api humatest.TestAPI, users map[string]string) {
+		id := createBasicLeaderboard(t, api, users["player2"])
+		hooks := fmt.Sprintf("/leaderboard/%s/webhooks", id)
+
+		// The test receiver listens on loopback, which is only allowed below.
+		for _, internal := range []string{server.URL, "https://10.0.0.1/hook", "https://169.254.169.254/latest/meta-data", "https://[::1]/hook", "https://0.0.0.0/hook"} {
+			internalResp := api.Post(hooks, authHeader(users["player2"]), map[string]any{"url": internal, "events": []string{"submission.created"}})
+			assert.Equal(t, 422, internalResp.Code, internal)
+		}
+		app.webhookClient = server.Client()
+		app.webhookAddrAllowed = func(net.IP) bool { return true }
+
+		for _, bad := range []map[string]any{
+			{"url": "http://example.com/hook", "events": []string{"submission.created"}},
+			{"url": "example.com/hook", "events": []string{"submission.created"}},
+			{"url": server.URL, "events": []string{"submission.deleted"}},
+			{"url": server.URL, "events": []string{}},
+		} {
+			badResp := api.Post(hooks, authHeader(users["player2"]), bad)
+			assert.Equal(t, 422, badResp.Code, bad)
+		}
+		notOwnerResp := api.Post(hooks, authHeader(users["player1"]), map[string]any{
+			"url": server.URL, "events": []string{"submission.created"},
+		})
+		assert.Equal(t, 403, notOwnerResp.Code)
+
+		createResp := api.Post(hooks, authHeader(users["player2"]), map[string]any{
+			"url":    server.URL,
+			"events": []string{"submission.created", "submission.verified", "leaderboard.closed"},
+		})
+		if !assert.Equal(t, 200, createResp.Code) {
+			return
+		}
+		var endpoint WebhookEndpoint
+		json.Unmarshal(createResp.Body.Bytes(), &endpoint)
+		assert.True(t, strings.HasPrefix(endpoint.Secret, "whsec_"))
+
+		listResp := api.Get(hooks, authHeader(users["player2"]))
+		assert.Equal(t, 200, listResp.Code)
+		assert.NotContains(t, listResp.Body.String(), endpoint.Secret)
+
+		postResp := api.Post(
+			fmt.Sprintf("/leaderboard/%s/submission", id),
+			authHeader(users["player1"]),
+			map[string]any{
+				"link":  "www.youtube.com",
+				"score": 10,
+			})
+		assert.Equal(t, 200, postResp.Code)
+		var submission SubmissionResponseBody
+		json.Unmarshal(postResp.Body.Bytes(), &submission)
+
+		// Not subscribed to comments.
+		api.Post(fmt.Sprintf("/leaderboard/%s/submission/%s/comment", id, submission.ID), authHeader(users["player1"]), map[string]any{"comment": "gg"})
+
+		sent, err := app.deliverWebhooks(ctx, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		received := receiver.take()
+		if assert.Equal(t, 1, len(received)) {
+			hook := received[0]
+			assert.Equal(t, webhookSignature(endpoint.Secret, hook.body), hook.header.Get("X-Signature"))
+			assert.Equal(t, "submission.created", hook.header.Get("X-Event-Name"))
+			var payload WebhookPayload
+			json.Unmarshal(hook.body, &payload)
+			assert.Equal(t, "submission.created", payload.Event)
+			assert.Equal(t, id, payload.Leaderboard)
+			assert.Equal(t, payload.ID.String(), hook.header.Get("X-Webhook-Delivery"))
+			var data SubmissionWebhookData
+			json.Unmarshal(payload.Data, &data)
+			assert.Equal(t, submission.ID, data.SubmissionID)
+			assert.Equal(t, "10", data.Score.String())
+		}
+
+		// A failed delivery is retried later, not on the next pass.
+		receiver.respondWith(500)
+		verifyResp := api.Patch(fmt.Sprintf("/leaderboard/%s/submission/%s/verify", id, submission.ID),
+			authHeader(users["player2"]),
+			map[string]any{"is_valid": true})
+		assert.Equal(t, 200, verifyResp.Code)
+		app.deliverWebhooks(ctx, time.Now())
+		assert.Equal(t, 1, len(receiver.take()))
+		sent, _ = app.deliverWebhooks(ctx, time.Now())
+		assert.Equal(t, 0, sent)
+
+		deliveries := fmt.Sprintf("%s/%s/deliveries", hooks, endpoint.ID)
+		failed := findDelivery(t, api, deliveries, users["player2"], "submission.verified")
+		assert.Equal(t, "pending", failed.Status)
+		assert.Equal(t, 1, failed.Attempts)
+		if assert.NotNil(t, failed.LastStatus) {
+			assert.Equal(t, 500, *failed.LastStatus)
+		}
+		assert.NotNil(t, failed.NextAttempt)
+		assert.Equal(t, "delivered", findDelivery(t, api, deliveries, users["player2"], "submission.created").Status)
+
+		// Pending deliveries can't be redelivered, delivered ones can.
+		redeliverResp := api.Post(fmt.Sprintf("%s/%s/redeliver", deliveries, failed.ID), authHeader(users["player2"]))
+		assert.Equal(t, 409, redeliverResp.Code)
+		receiver.respondWith(200)
+		delivered := findDelivery(t, api, deliveries, users["player2"], "submission.created")
+		redeliverResp = api.Post(fmt.Sprintf("%s/%s/redeliver", deliveries, delivered.ID), authHeader(users["player2"]))
+		assert.Equal(t, 200, redeliverResp.Code)
+		missingResp := api.Post(fmt.Sprintf("%s/%s/redeliver", deliveries, uuid.Must(uuid.NewV4())), authHeader(users["player2"]))
+		assert.Equal(t, 404, missingResp.Code)
+		app.deliverWebhooks(ctx, time.Now())
+		assert.Equal(t, 1, len(receiver.take()))
+
+		archiveResp := api.Post(fmt.Sprintf("/leaderboard/%s/archive", id), authHeader(users["player2"]))
+		assert.Equal(t, 200, archiveResp.Code)
+		assert.NoError(t, app.st.queueClosedLeaderboardWebhooks(ctx, time.Now()))
+		assert.NoError(t, app.st.queueClosedLeaderboardWebhooks(ctx, time.Now()))
+		app.deliverWebhooks(ctx, time.Now())
+		received = receiver.take()
+		if assert.Equal(t, 1, len(received)) {
+			assert.Equal(t, "leaderboard.closed", received[0].header.Get("X-Event-Name"))
+			assert.Contains(t, string(received[0].body), `"reason":"archived"`)
+		}
+
+		deleteResp := api.Delete(fmt.Sprintf("%s/%s", hooks, endpoint.ID), authHeader(users["player2"]))
+		assert.Equal(t, 200, deleteResp.Code)
+		listResp = api.Get(hooks, authHeader(users["player2"]))
+		assert.Contains(t, listResp.Body.String(), `"webhooks":[]`)
+	})
+}
+
+func TestWebhooksForDeletedLeaderboard(t *testing.T) {
+	receiver := &webhookReceiver{status: 500}
+	server := httptest.NewTLSServer(receiver)
+	defer server.Close()
+
+	WithAppState(t, func(ctx context.Context, app *App, api humatest.TestAPI, users map[string]string) {
+		app.webhookClient = server.Client()
+		app.webhookAddrAllowed = func(net.IP) bool { return true }
+		id := createBasicLeaderboard(t, api, users["player2"])
+		leaderboard := fmt.Sprintf("/leaderboard/%s", id)
+		createResp := api.Post(leaderboard+"/webhooks", authHeader(users["player2"]), map[string]any{
+			"url":    server.URL,
+			"events": []string{"submission.created"},
+		})
+		if !assert.Equal(t, 200, createResp.Code) {
+			return
+		}
+		var info LeaderboardInfo
+		json.Unmarshal(api.Get(leaderboard+"/info").Body.Bytes(), &info)
+
+		// A failed delivery is waiting to be retried when the leaderboard is deleted.
+		postResp := api.Post(leaderboard+"/submission", authHeader(users["player1"]), map[string]any{"link": "www.youtube.com", "score": 10})
+		assert.Equal(t, 200, postResp.Code)
+		sent, err := app.deliverWebhooks(ctx, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		receiver.take()
+
+		assert.Equal(t, 200, api.Delete(leaderboard, authHeader(users["player2"])).Code)
+		assert.NoError(t, app.st.queueWebhook(ctx, info.UUID, webhook_submission_created, SubmissionWebhookData{}))
+		sent, err = app.deliverWebhooks(ctx, time.Now().Add(24*time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.Equal(t, 0, len(receiver.take()))
+	})
+}
+
+func TestClosedWebhookAfterReopening(t *testing.T) {
+	receiver := &webhookReceiver{status: 200}
+	server := httptest.NewTLSServer(receiver)
+	defer server.Close()
+
+	WithAppState(t, func(ctx context.Context, app *App, api humatest.TestAPI, users map[string]string) {
+		app.webhookClient = server.Client()
+		app.webhookAddrAllowed = func(net.IP) bool { return true }
+		id := createBasicLeaderboard(t, api, users["player2"])
+		leaderboard := fmt.Sprintf("/leaderboard/%s", id)
+		createResp := api.Post(leaderboard+"/webhooks", authHeader(users["player2"]), map[string]any{
+			"url":    server.URL,
+			"events": []string{"leaderboard.closed"},
+		})
+		if !assert.Equal(t, 200, createResp.Code) {
+			return
+		}
+		closes := func(now time.Time) int {
+			t.Helper()
+			assert.NoError(t, app.st.queueClosedLeaderboardWebhooks(ctx, now))
+			app.deliverWebhooks(ctx, now)
+			return len(receiver.take())
+		}
+
+		// The leaderboard stops in a month.
+		assert.Equal(t, 1, closes(time.Now().AddDate(0, 2, 0)))
+		assert.Equal(t, 0, closes(time.Now().AddDate(0, 2, 0)))
+
+		// Moving stop later notifies the new close instead.
+		patchResp := api.Patch(leaderboard, authHeader(users["player2"]), map[string]any{
+			"stop": time.Now().AddDate(0, 3, 0).Format(time.RFC3339),
+		})
+		assert.Equal(t, 200, patchResp.Code)
+		assert.Equal(t, 0, closes(time.Now().AddDate(0, 2, 0).Add(time.Hour)))
+		assert.Equal(t, 1, closes(time.Now().AddDate(0, 4, 0)))
+	})
+}